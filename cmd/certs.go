@@ -0,0 +1,552 @@
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/bootstraptoken"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/certificates"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/kubeconfig"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/kubelet"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/manifests"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/apiclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+func newCmdCerts() *cobra.Command {
+	var certsCmd = &cobra.Command{
+		Use:   "certs",
+		Short: "Commands related to handling Kubernetes certificates",
+	}
+
+	certsCmd.AddCommand(newCmdCertsGenerateCSR())
+	certsCmd.AddCommand(newCmdCertsCheckExpiration())
+	certsCmd.AddCommand(newCmdCertsRenew())
+	certsCmd.AddCommand(newCmdCertsRotateCA())
+	certsCmd.AddCommand(newCmdCertsInspect())
+	return certsCmd
+}
+
+func newCmdCertsGenerateCSR() *cobra.Command {
+	var overwrite bool
+
+	var generateCSRCmd = &cobra.Command{
+		Use:   "generate-csr",
+		Short: "Generate keys and certificate signing requests",
+		Long: `Generate a private key and a certificate signing request for every certificate of this control plane node, for use with an external CA.
+
+Once the CSRs under /etc/kubernetes/pki have been signed, place the certificates next to their keys together with ca.crt (without ca.key) and run init. The certificates are then validated against the CA instead of being generated.
+
+Certificates whose key or signed certificate already exists are skipped. Use --overwrite to generate new keys for them, which invalidates certificates already signed for the old keys.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			return certificates.GenerateCSRs(cfg, overwrite)
+		},
+	}
+
+	generateCSRCmd.Flags().StringVar(
+		&cfgPath,
+		"config",
+		"",
+		"Path to a k8sbootstrap configuration file",
+	)
+	generateCSRCmd.Flags().StringVar(
+		&advertiseAddress,
+		"advertise-address",
+		"",
+		"The IP address the API Server will advertise it's listening on. If not set, the address of the default route interface is used",
+	)
+	generateCSRCmd.Flags().StringVar(
+		&nodeName,
+		"node-name",
+		"",
+		"Specify the node name. If not set, the lowercased hostname is used",
+	)
+	generateCSRCmd.Flags().StringVar(
+		&controlPlaneEndpoint,
+		"control-plane-endpoint",
+		"",
+		"Specify a stable IP address or DNS name, with an optional port, for the control plane",
+	)
+	generateCSRCmd.Flags().StringSliceVar(
+		&apiServerCertExtraSANs,
+		"apiserver-cert-extra-sans",
+		nil,
+		"Optional extra Subject Alternative Names (SANs) to use for the API Server serving certificate. Can be both IP addresses and DNS names",
+	)
+	generateCSRCmd.Flags().StringVar(
+		&serviceCIDR,
+		"service-cidr",
+		"",
+		"Use alternative range of IP address for service VIPs",
+	)
+	generateCSRCmd.Flags().BoolVar(
+		&overwrite,
+		"overwrite",
+		false,
+		"Replace existing keys and their certificates",
+	)
+
+	return generateCSRCmd
+}
+
+func newCmdCertsCheckExpiration() *cobra.Command {
+	var output string
+	var threshold time.Duration
+
+	var checkExpirationCmd = &cobra.Command{
+		Use:   "check-expiration",
+		Short: "Check certificates expiration for a Kubernetes cluster",
+		Long: `Check the expiration of the certificates in /etc/kubernetes/pki and of the client certificates used by the kubeconfigs in /etc/kubernetes.
+
+Exits with a non-zero status if any certificate expires within --threshold.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			now := time.Now()
+			expirations, err := certificates.CheckExpiration(now)
+			if err != nil {
+				return err
+			}
+
+			switch output {
+			case "table":
+				printExpirationTable(expirations)
+			case "json":
+				data, err := json.MarshalIndent(expirations, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+			case "yaml":
+				data, err := yaml.Marshal(expirations)
+				if err != nil {
+					return err
+				}
+				fmt.Print(string(data))
+			default:
+				return fmt.Errorf("unsupported output format %q, must be one of json, yaml or table", output)
+			}
+
+			var expiring []string
+			for _, expiration := range expirations {
+				if expiration.Expires.Before(now.Add(threshold)) {
+					expiring = append(expiring, expiration.Name)
+				}
+			}
+			if len(expiring) > 0 {
+				return fmt.Errorf("certificates expiring within %s: %v", threshold, expiring)
+			}
+			return nil
+		},
+	}
+
+	checkExpirationCmd.Flags().StringVarP(
+		&output,
+		"output",
+		"o",
+		"table",
+		"Output format, one of json, yaml or table",
+	)
+	checkExpirationCmd.Flags().DurationVar(
+		&threshold,
+		"threshold",
+		30*24*time.Hour,
+		"Exit with a non-zero status if any certificate expires within this duration",
+	)
+
+	return checkExpirationCmd
+}
+
+func newCmdCertsRenew() *cobra.Command {
+	var reuseKey bool
+
+	var renewCmd = &cobra.Command{
+		Use:   "renew <name>|all",
+		Short: "Renew certificates for a Kubernetes cluster",
+		Long: `Renew a certificate under /etc/kubernetes/pki, e.g. "apiserver" or "etcd/server", the kubelet client certificate as "kubelet-client", or all of them.
+
+Renewed certificates keep their subject, SANs and key usages, and are valid for the certificateValidityPeriod of --config. Kubeconfigs embedding a renewed certificate are updated, and the static pods or the kubelet using it are restarted.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgPath)
+			if err != nil {
+				return err
+			}
+
+			names := []string{args[0]}
+			if args[0] == "all" {
+				names, err = certificates.RenewableCertificates()
+				if err != nil {
+					return err
+				}
+			}
+
+			var staticPods []string
+			restartKubelet := false
+			for _, name := range names {
+				if err := certificates.RenewCertificate(name, reuseKey, cfg.CertificateValidityPeriod.Duration); err != nil {
+					return err
+				}
+				if name == certificates.KubeletClientCertName {
+					restartKubelet = true
+				}
+				for _, pod := range manifests.StaticPodsForCertificate(name) {
+					if !slices.Contains(staticPods, pod) {
+						staticPods = append(staticPods, pod)
+					}
+				}
+			}
+
+			for _, pod := range staticPods {
+				if _, err := os.Stat(fmt.Sprintf("/etc/kubernetes/manifests/%s.yaml", pod)); err != nil {
+					continue
+				}
+				if _, err := manifests.RestartStaticPod(pod); err != nil {
+					return err
+				}
+			}
+
+			if restartKubelet {
+				return kubelet.RestartKubelet()
+			}
+			return nil
+		},
+	}
+
+	renewCmd.Flags().StringVar(
+		&cfgPath,
+		"config",
+		"",
+		"Path to a k8sbootstrap configuration file",
+	)
+	renewCmd.Flags().BoolVar(
+		&reuseKey,
+		"reuse-key",
+		false,
+		"Keep the existing private key instead of generating a new one",
+	)
+
+	return renewCmd
+}
+
+func newCmdCertsRotateCA() *cobra.Command {
+	var finalize bool
+
+	var rotateCACmd = &cobra.Command{
+		Use:   "rotate-ca",
+		Short: "Replace the cluster CA",
+		Long: `Replace the cluster CA in two stages.
+
+Without --finalize, a new CA is generated and trusted alongside the current one in ca.crt, kubeconfigs and the cluster-info ConfigMap, every certificate signed by the current CA is reissued by the new one, and the control plane is restarted. Kubelet client certificates of worker nodes, signed by the old CA, keep working in the meantime.
+
+The CA files are only replaced on the node the command runs on, so the rotation is refused when the cluster has more than one control plane node.
+
+Once every node and workload trusts the new CA, run with --finalize to remove the old CA.
+
+Every step is recorded in /etc/kubernetes/pki/ca-rotation, so an interrupted run can be resumed by running the command again.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			return runRotateCA(cfg, finalize)
+		},
+	}
+
+	rotateCACmd.Flags().StringVar(
+		&cfgPath,
+		"config",
+		"",
+		"Path to a k8sbootstrap configuration file",
+	)
+	rotateCACmd.Flags().StringVar(
+		&advertiseAddress,
+		"advertise-address",
+		"",
+		"The IP address the API Server will advertise it's listening on. If not set, the address of the default route interface is used",
+	)
+	rotateCACmd.Flags().StringVar(
+		&controlPlaneEndpoint,
+		"control-plane-endpoint",
+		"",
+		"Specify a stable IP address or DNS name, with an optional port, for the control plane",
+	)
+	rotateCACmd.Flags().StringVar(
+		&nodeName,
+		"node-name",
+		"",
+		"Specify the node name. If not set, the lowercased hostname is used",
+	)
+	rotateCACmd.Flags().BoolVar(
+		&finalize,
+		"finalize",
+		false,
+		"Remove the old CA once the new one is trusted everywhere",
+	)
+
+	return rotateCACmd
+}
+
+// runRotateCA runs the CA rotation steps that have not completed yet.
+func runRotateCA(cfg *config.ClusterConfiguration, finalize bool) error {
+	state, err := certificates.CARotationState()
+	if err != nil {
+		return err
+	}
+
+	if err := checkSingleControlPlane(cfg); err != nil {
+		return err
+	}
+
+	if finalize {
+		if state != certificates.CARotationReissued {
+			return fmt.Errorf("no completed CA rotation to finalize, run certs rotate-ca first")
+		}
+
+		bundle, err := certificates.TrustCABundle("ca-new")
+		if err != nil {
+			return err
+		}
+		if err := restartControlPlane(cfg); err != nil {
+			return err
+		}
+		if err := updateClusterInfo(cfg, bundle); err != nil {
+			return err
+		}
+		if err := certificates.CleanupCARotation(); err != nil {
+			return err
+		}
+
+		fmt.Println("[rotate-ca] The old CA has been removed")
+		return nil
+	}
+
+	if state == "" {
+		if err := certificates.PrepareCARotation(cfg); err != nil {
+			return err
+		}
+		if err := certificates.SetCARotationState(certificates.CARotationPrepared); err != nil {
+			return err
+		}
+		state = certificates.CARotationPrepared
+	}
+
+	if state == certificates.CARotationPrepared {
+		bundle, err := certificates.TrustCABundle("ca-old", "ca-new")
+		if err != nil {
+			return err
+		}
+		if err := restartControlPlane(cfg); err != nil {
+			return err
+		}
+		if err := updateClusterInfo(cfg, bundle); err != nil {
+			return err
+		}
+		if err := certificates.SetCARotationState(certificates.CARotationTrusted); err != nil {
+			return err
+		}
+		state = certificates.CARotationTrusted
+	}
+
+	if state == certificates.CARotationTrusted {
+		bundle, err := certificates.TrustCABundle("ca-new", "ca-old")
+		if err != nil {
+			return err
+		}
+		reissued, err := certificates.ReissueCertificates(cfg.CertificateValidityPeriod.Duration)
+		if err != nil {
+			return err
+		}
+		if err := restartControlPlane(cfg); err != nil {
+			return err
+		}
+		if slices.Contains(reissued, certificates.KubeletClientCertName) {
+			if err := kubelet.RestartKubelet(); err != nil {
+				return err
+			}
+		}
+		if err := updateClusterInfo(cfg, bundle); err != nil {
+			return err
+		}
+		if err := certificates.SetCARotationState(certificates.CARotationReissued); err != nil {
+			return err
+		}
+	}
+
+	hash, err := certificates.CACertHash()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[rotate-ca] The new CA is in use, its hash for --discovery-token-ca-cert-hash is %s\n", hash)
+	fmt.Println("[rotate-ca] Once every node and workload trusts the new CA, run certs rotate-ca --finalize")
+	return nil
+}
+
+// adminClient builds a client from admin.conf. client-go reads the CA and
+// client certificate files once, when the transport is created, so a new
+// client is needed whenever ca.crt or admin.crt change during the rotation.
+func adminClient() (kubernetes.Interface, error) {
+	return kubeconfig.ClientSetFromFile("/etc/kubernetes/admin.conf")
+}
+
+// checkSingleControlPlane fails when an API server runs on a node other than
+// this one. Those nodes would keep the old ca.crt and reject the certificates
+// reissued by the new CA.
+func checkSingleControlPlane(cfg *config.ClusterConfiguration) error {
+	client, err := adminClient()
+	if err != nil {
+		return err
+	}
+
+	pods, err := client.CoreV1().Pods("kube-system").List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "component=kube-apiserver,tier=control-plane",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list API server pods: %w", err)
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != cfg.NodeName {
+			return fmt.Errorf("an API server also runs on %s, the CA can only be rotated on clusters with a single control plane node", pod.Spec.NodeName)
+		}
+	}
+	return nil
+}
+
+// restartControlPlane restarts the API server first and waits for the new
+// instance to be healthy before restarting the components that connect to it.
+func restartControlPlane(cfg *config.ClusterConfiguration) error {
+	for _, pod := range []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler"} {
+		if _, err := os.Stat(fmt.Sprintf("/etc/kubernetes/manifests/%s.yaml", pod)); err != nil {
+			continue
+		}
+		restartedAt, err := manifests.RestartStaticPod(pod)
+		if err != nil {
+			return err
+		}
+
+		if pod == "kube-apiserver" {
+			client, err := adminClient()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("[rotate-ca] Waiting up to %s for the API server to restart\n", apiServerHealthTimeout)
+			if err := manifests.WaitForStaticPodRestart(client, pod, cfg.NodeName, restartedAt, apiServerHealthTimeout); err != nil {
+				return err
+			}
+			if err := apiclient.WaitForAPIServer(client, apiServerHealthTimeout); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func updateClusterInfo(cfg *config.ClusterConfiguration, caBundle []byte) error {
+	client, err := adminClient()
+	if err != nil {
+		return err
+	}
+
+	server, err := cfg.ControlPlaneURL()
+	if err != nil {
+		return err
+	}
+	return bootstraptoken.CreateClusterInfo(client, server, caBundle)
+}
+
+func newCmdCertsInspect() *cobra.Command {
+	return &cobra.Command{
+		Use:          "inspect <name>",
+		Short:        "Show a certificate from /etc/kubernetes/pki",
+		Long:         `Show the subject, issuer, validity, key usages and Subject Alternative Names of a certificate under /etc/kubernetes/pki, e.g. "apiserver" or "etcd/server".`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cert, err := certificates.LoadCertificate(args[0])
+			if err != nil {
+				return err
+			}
+
+			printCertificate(cert)
+			return nil
+		},
+	}
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:        "Any",
+	x509.ExtKeyUsageServerAuth: "ServerAuth",
+	x509.ExtKeyUsageClientAuth: "ClientAuth",
+}
+
+func printCertificate(cert *x509.Certificate) {
+	var extKeyUsages []string
+	for _, usage := range cert.ExtKeyUsage {
+		name, ok := extKeyUsageNames[usage]
+		if !ok {
+			name = fmt.Sprintf("%d", usage)
+		}
+		extKeyUsages = append(extKeyUsages, name)
+	}
+
+	var ips []string
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Subject:\t%s\n", cert.Subject)
+	fmt.Fprintf(w, "Issuer:\t%s\n", cert.Issuer)
+	fmt.Fprintf(w, "Serial:\t%X\n", cert.SerialNumber)
+	fmt.Fprintf(w, "Not Before:\t%s\n", cert.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(w, "Not After:\t%s\n", cert.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(w, "Public Key:\t%s\n", cert.PublicKeyAlgorithm)
+	fmt.Fprintf(w, "CA:\t%t\n", cert.IsCA)
+	fmt.Fprintf(w, "Extended Key Usages:\t%s\n", strings.Join(extKeyUsages, ", "))
+	fmt.Fprintf(w, "DNS Names:\t%s\n", strings.Join(cert.DNSNames, ", "))
+	fmt.Fprintf(w, "IP Addresses:\t%s\n", strings.Join(ips, ", "))
+	w.Flush()
+}
+
+func printExpirationTable(expirations []certificates.CertificateExpiration) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CERTIFICATE\tEXPIRES\tRESIDUAL TIME\tCERTIFICATE AUTHORITY\tEXTERNALLY MANAGED")
+	for _, expiration := range expirations {
+		ca := expiration.CertificateAuthority
+		if expiration.IsCertificateAuthority {
+			ca = "-"
+		}
+		externallyManaged := "no"
+		if expiration.ExternallyManaged {
+			externallyManaged = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			expiration.Name,
+			expiration.Expires.Format("Jan 02, 2006 15:04 MST"),
+			expiration.ResidualTime,
+			ca,
+			externallyManaged,
+		)
+	}
+	w.Flush()
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+)
+
+// loadConfig reads the --config file and applies any flags set on cmd on
+// top of it.
+func loadConfig(cmd *cobra.Command) (*config.ClusterConfiguration, error) {
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Flags().Changed("node-name") {
+		cfg.NodeName = nodeName
+	}
+	if cmd.Flags().Changed("advertise-address") {
+		cfg.AdvertiseAddress = advertiseAddress
+	}
+	if cmd.Flags().Changed("control-plane-endpoint") {
+		cfg.ControlPlaneEndpoint = controlPlaneEndpoint
+	}
+	if cmd.Flags().Changed("pod-network-cidr") {
+		cfg.Networking.PodSubnet = podNetworkCIDR
+	}
+	if cmd.Flags().Changed("service-cidr") {
+		cfg.Networking.ServiceSubnet = serviceCIDR
+	}
+	if cmd.Flags().Changed("apiserver-cert-extra-sans") {
+		cfg.APIServer.CertSANs = append(cfg.APIServer.CertSANs, apiServerCertExtraSANs...)
+	}
+
+	if err := config.SetDynamicDefaults(cfg); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -2,17 +2,37 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/bootstraptoken"
 	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/certificates"
 	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/kubeconfig"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/kubelet"
 	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/manifests"
 	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/preflight"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/uploadcerts"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/apiclient"
+)
+
+const (
+	apiServerHealthTimeout = 4 * time.Minute
+	certificateKeyTTL      = 2 * time.Hour
 )
 
 var (
-	advertiseAddress string
-	podNetworkCIDR   string
+	cfgPath                string
+	advertiseAddress       string
+	nodeName               string
+	controlPlaneEndpoint   string
+	podNetworkCIDR         string
+	serviceCIDR            string
+	uploadCerts            bool
+	certificateKey         string
+	apiServerCertExtraSANs []string
 )
 
 func newCmdInit() *cobra.Command {
@@ -22,40 +42,182 @@ func newCmdInit() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			fmt.Println("[init] Starting k8sbootstrap init...")
 
-			if err := preflight.RunPreflightChecks(); err != nil {
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := preflight.RunPreflightChecks(cfg); err != nil {
 				fmt.Printf("[preflight] Preflight checks failed: %s\n", err)
 			}
 
-			if err := certificates.SetupCerts(advertiseAddress); err != nil {
+			if err := certificates.SetupCerts(cfg); err != nil {
 				fmt.Printf("[certificate] Certificate creation failed: %s\n", err)
 			}
 
-			if err := kubeconfig.SetupKubeconfigs(advertiseAddress); err != nil {
+			if err := kubeconfig.SetupKubeconfigs(cfg); err != nil {
 				fmt.Printf("[kubeconfig] Kubeconfig creation failed: %s\n", err)
 			}
 
-			if err := manifests.SetupStaticPodManifests(advertiseAddress); err != nil {
+			if err := kubeconfig.SetupSuperAdminKubeconfig(cfg); err != nil {
+				fmt.Printf("[kubeconfig] Super-admin kubeconfig creation failed: %s\n", err)
+			}
+
+			if err := kubelet.SetupKubelet(cfg); err != nil {
+				fmt.Printf("[kubelet] Kubelet setup failed: %s\n", err)
+			}
+
+			if err := manifests.SetupStaticPodManifests(cfg); err != nil {
 				fmt.Printf("[manifests] Static pod manifest creation failed: %s\n", err)
 			}
 
+			if err := runClusterAdminsBinding(); err != nil {
+				fmt.Printf("[kubeconfig] Granting cluster-admin to admin.conf failed: %s\n", err)
+			}
+
+			if uploadCerts {
+				if err := runUploadCerts(cfg); err != nil {
+					fmt.Printf("[upload-certs] Certificate upload failed: %s\n", err)
+				}
+			}
+
 			return nil
 		},
 	}
 
+	initCmd.Flags().StringVar(
+		&cfgPath,
+		"config",
+		"",
+		"Path to a k8sbootstrap configuration file",
+	)
 	initCmd.Flags().StringVar(
 		&advertiseAddress,
 		"advertise-address",
 		"",
-		"The IP address the API Server will advertise it's listening on",
+		"The IP address the API Server will advertise it's listening on. If not set, the address of the default route interface is used",
+	)
+	initCmd.Flags().StringVar(
+		&nodeName,
+		"node-name",
+		"",
+		"Specify the node name. If not set, the lowercased hostname is used",
+	)
+	initCmd.Flags().StringVar(
+		&controlPlaneEndpoint,
+		"control-plane-endpoint",
+		"",
+		"Specify a stable IP address or DNS name, with an optional port, for the control plane",
 	)
 	initCmd.Flags().StringVar(
 		&podNetworkCIDR,
 		"pod-network-cidr",
-		"10.244.0.0/16",
-		"Specify range of IP addresses for the pod network",
+		config.DefaultPodSubnet,
+		"Specify range of IP addresses for the pod network. A comma-separated IPv4 and IPv6 pair enables dual-stack",
+	)
+	initCmd.Flags().StringVar(
+		&serviceCIDR,
+		"service-cidr",
+		config.DefaultServiceSubnet,
+		"Use alternative range of IP address for service VIPs. A comma-separated IPv4 and IPv6 pair enables dual-stack",
+	)
+	initCmd.Flags().StringSliceVar(
+		&apiServerCertExtraSANs,
+		"apiserver-cert-extra-sans",
+		nil,
+		"Optional extra Subject Alternative Names (SANs) to use for the API Server serving certificate. Can be both IP addresses and DNS names",
+	)
+	initCmd.Flags().BoolVar(
+		&uploadCerts,
+		"upload-certs",
+		false,
+		"Upload the shared control plane certificates to a Secret so other control plane nodes can join",
+	)
+	initCmd.Flags().StringVar(
+		&certificateKey,
+		"certificate-key",
+		"",
+		"Key used to encrypt the uploaded certificates. If not set, a random key is generated",
 	)
-
-	initCmd.MarkFlagRequired("advertise-address")
 
 	return initCmd
 }
+
+// runClusterAdminsBinding uses super-admin.conf to create the
+// ClusterRoleBinding that admin.conf, and every later phase using it,
+// depends on.
+func runClusterAdminsBinding() error {
+	client, err := kubeconfig.ClientSetFromFile(kubeconfig.SuperAdminKubeconfigPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[kubeconfig] Waiting up to %s for the API server to become healthy\n", apiServerHealthTimeout)
+	if err := apiclient.WaitForAPIServer(client, apiServerHealthTimeout); err != nil {
+		return err
+	}
+
+	return kubeconfig.CreateClusterAdminsBinding(client)
+}
+
+// runUploadCerts creates a bootstrap token and the cluster-info ConfigMap,
+// uploads the encrypted shared certificates owned by that token, and prints
+// the command to join further control plane nodes.
+func runUploadCerts(cfg *config.ClusterConfiguration) error {
+	client, err := kubeconfig.ClientSetFromFile("/etc/kubernetes/admin.conf")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[upload-certs] Waiting up to %s for the API server to become healthy\n", apiServerHealthTimeout)
+	if err := apiclient.WaitForAPIServer(client, apiServerHealthTimeout); err != nil {
+		return err
+	}
+
+	token, err := bootstraptoken.GenerateToken()
+	if err != nil {
+		return err
+	}
+	tokenSecret, err := bootstraptoken.CreateToken(client, token, certificateKeyTTL,
+		"Proxy for managing TTL for the k8sbootstrap-certs secret")
+	if err != nil {
+		return err
+	}
+
+	server, err := cfg.ControlPlaneURL()
+	if err != nil {
+		return err
+	}
+	caData, err := os.ReadFile("/etc/kubernetes/pki/ca.crt")
+	if err != nil {
+		return fmt.Errorf("failed to read CA cert: %w", err)
+	}
+	if err := bootstraptoken.CreateClusterInfo(client, server, caData); err != nil {
+		return err
+	}
+
+	key := certificateKey
+	if key == "" {
+		key, err = uploadcerts.CreateCertificateKey()
+		if err != nil {
+			return err
+		}
+	}
+	if err := uploadcerts.UploadCerts(client, cfg, key, tokenSecret); err != nil {
+		return err
+	}
+
+	caCertHash, err := certificates.CACertHash()
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimPrefix(server, "https://")
+	fmt.Printf("[upload-certs] Using certificate key:\n%s\n\n", key)
+	fmt.Printf("The certificate key and token expire in %s. Join further control plane nodes with:\n\n", certificateKeyTTL)
+	fmt.Printf("  k8sbootstrap join %s --control-plane \\\n", endpoint)
+	fmt.Printf("    --token %s \\\n", token)
+	fmt.Printf("    --discovery-token-ca-cert-hash %s \\\n", caCertHash)
+	fmt.Printf("    --certificate-key %s\n", key)
+	return nil
+}
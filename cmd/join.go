@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/certificates"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/discovery"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/etcd"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/kubeconfig"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/kubelet"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/manifests"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/preflight"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/uploadcerts"
+)
+
+var (
+	controlPlane bool
+	token        string
+	caCertHash   string
+)
+
+func newCmdJoin() *cobra.Command {
+	var joinCmd = &cobra.Command{
+		Use:   "join <control-plane-endpoint>",
+		Short: "Run this command on a node to join it to an existing cluster",
+		Long: "Joins this node to an existing cluster as an additional control plane node.\n\n" +
+			"The shared certificates (the cluster, front proxy and etcd CAs, sa.key and sa.pub)\n" +
+			"are downloaded when --certificate-key is given, otherwise they must be copied to\n" +
+			"/etc/kubernetes/pki from an existing control plane node. The same --config used\n" +
+			"for init should be passed so all control plane nodes are configured alike.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !controlPlane {
+				return fmt.Errorf("only control plane nodes can be joined, please pass --control-plane")
+			}
+
+			fmt.Println("[join] Starting k8sbootstrap join...")
+
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			cfg.ControlPlaneEndpoint = args[0]
+			if _, _, err := cfg.GetControlPlaneEndpoint(); err != nil {
+				return err
+			}
+
+			if err := preflight.RunPreflightChecks(cfg); err != nil {
+				fmt.Printf("[preflight] Preflight checks failed: %s\n", err)
+			}
+
+			if certificateKey != "" {
+				if err := downloadCerts(cfg); err != nil {
+					return fmt.Errorf("[download-certs] %w", err)
+				}
+			}
+
+			if err := certificates.CheckSharedCertificates(cfg); err != nil {
+				return fmt.Errorf("[certificate] %w", err)
+			}
+
+			if err := certificates.SetupNodeCerts(cfg); err != nil {
+				return fmt.Errorf("[certificate] Certificate creation failed: %w", err)
+			}
+
+			if err := kubeconfig.SetupKubeconfigs(cfg); err != nil {
+				return fmt.Errorf("[kubeconfig] Kubeconfig creation failed: %w", err)
+			}
+
+			if err := kubelet.SetupKubelet(cfg); err != nil {
+				return fmt.Errorf("[kubelet] Kubelet setup failed: %w", err)
+			}
+
+			if cfg.Etcd.External == nil {
+				if err := etcd.JoinStackedEtcdMember(cfg); err != nil {
+					return fmt.Errorf("[etcd] Joining etcd cluster failed: %w", err)
+				}
+			}
+
+			if err := manifests.SetupControlPlaneStaticPodManifests(cfg); err != nil {
+				return fmt.Errorf("[manifests] Static pod manifest creation failed: %w", err)
+			}
+
+			fmt.Println("[join] This node has joined the control plane")
+			return nil
+		},
+	}
+
+	joinCmd.Flags().StringVar(
+		&cfgPath,
+		"config",
+		"",
+		"Path to a k8sbootstrap configuration file",
+	)
+	joinCmd.Flags().StringVar(
+		&advertiseAddress,
+		"advertise-address",
+		"",
+		"The IP address the API Server on this node will advertise it's listening on. If not set, the address of the default route interface is used",
+	)
+	joinCmd.Flags().StringSliceVar(
+		&apiServerCertExtraSANs,
+		"apiserver-cert-extra-sans",
+		nil,
+		"Optional extra Subject Alternative Names (SANs) to use for the API Server serving certificate. Can be both IP addresses and DNS names",
+	)
+	joinCmd.Flags().StringVar(
+		&nodeName,
+		"node-name",
+		"",
+		"Specify the node name. If not set, the lowercased hostname is used",
+	)
+	joinCmd.Flags().BoolVar(
+		&controlPlane,
+		"control-plane",
+		false,
+		"Create a new control plane instance on this node",
+	)
+	joinCmd.Flags().StringVar(
+		&token,
+		"token",
+		"",
+		"Bootstrap token used to download the shared certificates",
+	)
+	joinCmd.Flags().StringVar(
+		&caCertHash,
+		"discovery-token-ca-cert-hash",
+		"",
+		"Hash of the cluster CA public key (sha256:<hex>) used to validate the cluster",
+	)
+	joinCmd.Flags().StringVar(
+		&certificateKey,
+		"certificate-key",
+		"",
+		"Key printed by 'init --upload-certs' used to decrypt the shared certificates",
+	)
+
+	return joinCmd
+}
+
+func downloadCerts(cfg *config.ClusterConfiguration) error {
+	if token == "" || caCertHash == "" {
+		return fmt.Errorf("--token and --discovery-token-ca-cert-hash are required with --certificate-key")
+	}
+
+	server, err := cfg.ControlPlaneURL()
+	if err != nil {
+		return err
+	}
+
+	client, err := discovery.ClientForToken(server, token, caCertHash)
+	if err != nil {
+		return err
+	}
+
+	return uploadcerts.DownloadCerts(client, cfg, certificateKey)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/containerd"
+)
+
+func newCmdNode() *cobra.Command {
+	var nodeCmd = &cobra.Command{
+		Use:   "node",
+		Short: "Commands related to preparing a node",
+	}
+
+	nodeCmd.AddCommand(newCmdNodePrepare())
+	return nodeCmd
+}
+
+func newCmdNodePrepare() *cobra.Command {
+	var containerRuntime string
+
+	var prepareCmd = &cobra.Command{
+		Use:   "prepare",
+		Short: "Configure the container runtime of this node",
+		Long: `Render the container runtime configuration for this node before running init or join.
+
+For containerd, /etc/containerd/config.toml is written with the cgroup driver of kubelet.cgroupDriver (systemd by default) and the sandbox image matching the control plane images, and the containerRuntime.registryMirrors of --config are written under /etc/containerd/certs.d. Existing files that differ are shown as a diff and backed up, then containerd is restarted.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if containerRuntime != "containerd" {
+				return fmt.Errorf("unsupported container runtime %q, only containerd is supported", containerRuntime)
+			}
+
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			return containerd.SetupContainerd(cfg)
+		},
+	}
+
+	prepareCmd.Flags().StringVar(
+		&cfgPath,
+		"config",
+		"",
+		"Path to a k8sbootstrap configuration file",
+	)
+	prepareCmd.Flags().StringVar(
+		&containerRuntime,
+		"runtime",
+		"containerd",
+		"The container runtime to configure",
+	)
+
+	return prepareCmd
+}
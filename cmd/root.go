@@ -12,5 +12,8 @@ func NewK8sBootstrapCmd() *cobra.Command {
 	}
 
 	cmds.AddCommand(newCmdInit())
+	cmds.AddCommand(newCmdJoin())
+	cmds.AddCommand(newCmdCerts())
+	cmds.AddCommand(newCmdNode())
 	return cmds
 }
@@ -0,0 +1,392 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/network"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/runtime"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// ClusterConfiguration holds the settings shared by all init phases. It is
+// loaded from the file passed with --config and completed with defaults.
+type ClusterConfiguration struct {
+	ClusterName          string                `json:"clusterName,omitempty"`
+	NodeName             string                `json:"nodeName,omitempty"`
+	AdvertiseAddress     string                `json:"advertiseAddress,omitempty"`
+	ControlPlaneEndpoint string                `json:"controlPlaneEndpoint,omitempty"`
+	Etcd                 Etcd                  `json:"etcd,omitempty"`
+	APIServer            APIServer             `json:"apiServer,omitempty"`
+	ControllerManager    ControlPlaneComponent `json:"controllerManager,omitempty"`
+	Scheduler            ControlPlaneComponent `json:"scheduler,omitempty"`
+	Networking           Networking            `json:"networking,omitempty"`
+	Kubelet              Kubelet               `json:"kubelet,omitempty"`
+	ContainerRuntime     ContainerRuntime      `json:"containerRuntime,omitempty"`
+	EncryptionAlgorithm  string                `json:"encryptionAlgorithm,omitempty"`
+
+	CertificateValidityPeriod   *metav1.Duration `json:"certificateValidityPeriod,omitempty"`
+	CACertificateValidityPeriod *metav1.Duration `json:"caCertificateValidityPeriod,omitempty"`
+}
+
+type Networking struct {
+	PodSubnet     string `json:"podSubnet,omitempty"`
+	ServiceSubnet string `json:"serviceSubnet,omitempty"`
+	DNSDomain     string `json:"dnsDomain,omitempty"`
+}
+
+type Kubelet struct {
+	// CgroupDriver must match the container runtime. When empty, the driver
+	// of the runtime is used.
+	CgroupDriver string `json:"cgroupDriver,omitempty"`
+}
+
+type ContainerRuntime struct {
+	// RegistryMirrors maps a registry host, such as "docker.io", to the
+	// mirror endpoints containerd tries before the registry itself.
+	RegistryMirrors map[string][]string `json:"registryMirrors,omitempty"`
+}
+
+type Etcd struct {
+	Resources ResourceRequests `json:"resources,omitempty"`
+	// External points the API server at an existing etcd cluster instead of
+	// running a local etcd static pod.
+	External *ExternalEtcd `json:"external,omitempty"`
+}
+
+type ExternalEtcd struct {
+	Endpoints []string `json:"endpoints"`
+	CAFile    string   `json:"caFile,omitempty"`
+	CertFile  string   `json:"certFile,omitempty"`
+	KeyFile   string   `json:"keyFile,omitempty"`
+}
+
+type ControlPlaneComponent struct {
+	Resources ResourceRequests `json:"resources,omitempty"`
+}
+
+type APIServer struct {
+	ControlPlaneComponent
+	// CertSANs are extra IP addresses and DNS names added to the API server
+	// serving certificate.
+	CertSANs []string `json:"certSANs,omitempty"`
+}
+
+// ResourceRequests are the CPU and memory requests set on a static pod
+// container, written as Kubernetes quantities (e.g. "250m", "100Mi").
+type ResourceRequests struct {
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+}
+
+func Load(path string) (*ClusterConfiguration, error) {
+	cfg := &ClusterConfiguration{}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	SetDefaults(cfg)
+	return cfg, nil
+}
+
+func Validate(cfg *ClusterConfiguration) error {
+	if errs := validation.IsDNS1123Subdomain(cfg.ClusterName); len(errs) > 0 {
+		return fmt.Errorf("invalid clusterName %q: %s", cfg.ClusterName, strings.Join(errs, ", "))
+	}
+
+	// Dotted names such as FQDN hostnames break node registration, so the
+	// node name must be a single DNS-1123 label.
+	if errs := validation.IsDNS1123Label(cfg.NodeName); len(errs) > 0 {
+		if short, _, found := strings.Cut(cfg.NodeName, "."); found && len(validation.IsDNS1123Label(short)) == 0 {
+			return fmt.Errorf("invalid nodeName %q: must not contain dots, set --node-name, e.g. to %q", cfg.NodeName, short)
+		}
+		return fmt.Errorf("invalid nodeName %q: %s, set --node-name to a valid name", cfg.NodeName, strings.Join(errs, ", "))
+	}
+
+	if cfg.AdvertiseAddress != "" {
+		ip := net.ParseIP(cfg.AdvertiseAddress)
+		if ip == nil {
+			return fmt.Errorf("advertiseAddress %q is not a valid IP address", cfg.AdvertiseAddress)
+		}
+		if err := network.ValidateHostAddress(ip); err != nil {
+			return fmt.Errorf("invalid advertiseAddress: %w", err)
+		}
+	}
+
+	components := map[string]ResourceRequests{
+		"etcd":              cfg.Etcd.Resources,
+		"apiServer":         cfg.APIServer.Resources,
+		"controllerManager": cfg.ControllerManager.Resources,
+		"scheduler":         cfg.Scheduler.Resources,
+	}
+	for name, requests := range components {
+		if err := validateResourceRequests(requests); err != nil {
+			return fmt.Errorf("invalid %s resources: %w", name, err)
+		}
+	}
+
+	if cfg.ControlPlaneEndpoint != "" {
+		if _, _, err := cfg.GetControlPlaneEndpoint(); err != nil {
+			return err
+		}
+	}
+
+	for _, san := range cfg.APIServer.CertSANs {
+		if err := validateCertSAN(san); err != nil {
+			return fmt.Errorf("invalid apiServer.certSANs: %w", err)
+		}
+	}
+
+	if cfg.Etcd.External != nil {
+		if err := validateExternalEtcd(cfg.Etcd.External); err != nil {
+			return fmt.Errorf("invalid etcd.external: %w", err)
+		}
+	}
+
+	if cfg.Kubelet.CgroupDriver != "" &&
+		cfg.Kubelet.CgroupDriver != runtime.CgroupDriverSystemd && cfg.Kubelet.CgroupDriver != runtime.CgroupDriverCgroupfs {
+		return fmt.Errorf("invalid kubelet.cgroupDriver %q, must be %s or %s",
+			cfg.Kubelet.CgroupDriver, runtime.CgroupDriverSystemd, runtime.CgroupDriverCgroupfs)
+	}
+
+	if err := validateRegistryMirrors(cfg.ContainerRuntime.RegistryMirrors); err != nil {
+		return fmt.Errorf("invalid containerRuntime.registryMirrors: %w", err)
+	}
+
+	if err := validateNetworking(cfg.Networking); err != nil {
+		return fmt.Errorf("invalid networking: %w", err)
+	}
+
+	if !slices.Contains(EncryptionAlgorithms, cfg.EncryptionAlgorithm) {
+		return fmt.Errorf("invalid encryptionAlgorithm %q, must be one of %v", cfg.EncryptionAlgorithm, EncryptionAlgorithms)
+	}
+
+	if cfg.CertificateValidityPeriod.Duration <= 0 {
+		return fmt.Errorf("certificateValidityPeriod must be positive")
+	}
+	if cfg.CACertificateValidityPeriod.Duration <= 0 {
+		return fmt.Errorf("caCertificateValidityPeriod must be positive")
+	}
+	if cfg.CertificateValidityPeriod.Duration > cfg.CACertificateValidityPeriod.Duration {
+		return fmt.Errorf("certificateValidityPeriod %s exceeds caCertificateValidityPeriod %s",
+			cfg.CertificateValidityPeriod.Duration, cfg.CACertificateValidityPeriod.Duration)
+	}
+
+	return nil
+}
+
+// validateCertSAN accepts an IP address, a DNS name or a wildcard DNS name
+// such as "*.example.com".
+func validateCertSAN(san string) error {
+	if net.ParseIP(san) != nil {
+		return nil
+	}
+	if len(validation.IsDNS1123Subdomain(san)) == 0 || len(validation.IsWildcardDNS1123Subdomain(san)) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%q is neither an IP address nor a valid DNS name", san)
+}
+
+func validateExternalEtcd(external *ExternalEtcd) error {
+	if len(external.Endpoints) == 0 {
+		return fmt.Errorf("at least one endpoint is required")
+	}
+
+	for _, endpoint := range external.Endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("endpoint %q is not a valid URL", endpoint)
+		}
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return fmt.Errorf("endpoint %q must use http or https", endpoint)
+		}
+		if u.Scheme == "https" && (external.CAFile == "" || external.CertFile == "" || external.KeyFile == "") {
+			return fmt.Errorf("caFile, certFile and keyFile are required for https endpoint %q", endpoint)
+		}
+	}
+
+	for _, path := range []string{external.CAFile, external.CertFile, external.KeyFile} {
+		if path != "" && !filepath.IsAbs(path) {
+			return fmt.Errorf("%q must be an absolute path", path)
+		}
+	}
+	return nil
+}
+
+func validateRegistryMirrors(mirrors map[string][]string) error {
+	for registry, endpoints := range mirrors {
+		host, _, err := net.SplitHostPort(registry)
+		if err != nil {
+			host = registry
+		}
+		if len(validation.IsDNS1123Subdomain(host)) > 0 && net.ParseIP(host) == nil {
+			return fmt.Errorf("registry %q is not a valid host", registry)
+		}
+		if len(endpoints) == 0 {
+			return fmt.Errorf("registry %q has no mirror endpoints", registry)
+		}
+		for _, endpoint := range endpoints {
+			u, err := url.Parse(endpoint)
+			if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+				return fmt.Errorf("mirror %q of registry %q is not a valid http or https URL", endpoint, registry)
+			}
+		}
+	}
+	return nil
+}
+
+func validateNetworking(networking Networking) error {
+	if errs := validation.IsDNS1123Subdomain(networking.DNSDomain); len(errs) > 0 {
+		return fmt.Errorf("invalid dnsDomain %q: %s", networking.DNSDomain, strings.Join(errs, ", "))
+	}
+
+	podSubnets, err := validateSubnets("podSubnet", networking.PodSubnet)
+	if err != nil {
+		return err
+	}
+
+	serviceSubnets, err := validateSubnets("serviceSubnet", networking.ServiceSubnet)
+	if err != nil {
+		return err
+	}
+
+	if len(podSubnets) != len(serviceSubnets) ||
+		network.IsIPv6CIDR(podSubnets[0]) != network.IsIPv6CIDR(serviceSubnets[0]) {
+		return fmt.Errorf("podSubnet %q and serviceSubnet %q must use the same IP families in the same order",
+			networking.PodSubnet, networking.ServiceSubnet)
+	}
+
+	for _, podSubnet := range podSubnets {
+		for _, serviceSubnet := range serviceSubnets {
+			if network.SubnetsOverlap(podSubnet, serviceSubnet) {
+				return fmt.Errorf("podSubnet %s overlaps with serviceSubnet %s", podSubnet, serviceSubnet)
+			}
+		}
+	}
+
+	if _, err := network.APIServerVirtualIP(networking.ServiceSubnet); err != nil {
+		return fmt.Errorf("serviceSubnet: %w", err)
+	}
+
+	return nil
+}
+
+// validateSubnets accepts a single CIDR or a dual-stack pair with one IPv4
+// and one IPv6 CIDR.
+func validateSubnets(name string, cidrs string) ([]*net.IPNet, error) {
+	subnets, err := network.ParseCIDRs(cidrs)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %w", name, cidrs, err)
+	}
+
+	switch len(subnets) {
+	case 1:
+	case 2:
+		if network.IsIPv6CIDR(subnets[0]) == network.IsIPv6CIDR(subnets[1]) {
+			return nil, fmt.Errorf("%s %q: dual-stack subnets must contain one IPv4 and one IPv6 CIDR", name, cidrs)
+		}
+	default:
+		return nil, fmt.Errorf("%s %q: expected at most two comma-separated CIDRs", name, cidrs)
+	}
+	return subnets, nil
+}
+
+// GetControlPlaneEndpoint returns the host and port clients use to reach the
+// API server: the controlPlaneEndpoint if set, otherwise this node's
+// advertise address.
+func (cfg *ClusterConfiguration) GetControlPlaneEndpoint() (string, int, error) {
+	if cfg.ControlPlaneEndpoint == "" {
+		return cfg.AdvertiseAddress, DefaultAPIServerPort, nil
+	}
+
+	// A bare IPv6 literal has colons but no port.
+	if net.ParseIP(cfg.ControlPlaneEndpoint) != nil {
+		return cfg.ControlPlaneEndpoint, DefaultAPIServerPort, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(cfg.ControlPlaneEndpoint)
+	if err != nil {
+		if strings.Contains(cfg.ControlPlaneEndpoint, ":") {
+			return "", 0, fmt.Errorf("invalid controlPlaneEndpoint %q: %w", cfg.ControlPlaneEndpoint, err)
+		}
+		return cfg.ControlPlaneEndpoint, DefaultAPIServerPort, nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid controlPlaneEndpoint %q: port must be between 1 and 65535", cfg.ControlPlaneEndpoint)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("invalid controlPlaneEndpoint %q: missing host", cfg.ControlPlaneEndpoint)
+	}
+	return host, port, nil
+}
+
+// ControlPlaneURL is the API server URL written into kubeconfigs.
+func (cfg *ClusterConfiguration) ControlPlaneURL() (string, error) {
+	host, port, err := cfg.GetControlPlaneEndpoint()
+	if err != nil {
+		return "", err
+	}
+	return network.FormatURL("https", host, port), nil
+}
+
+// HasIPv4 reports whether any pod or service subnet is IPv4.
+func (cfg *ClusterConfiguration) HasIPv4() bool {
+	for _, cidrs := range []string{cfg.Networking.PodSubnet, cfg.Networking.ServiceSubnet} {
+		subnets, err := network.ParseCIDRs(cidrs)
+		if err != nil {
+			continue
+		}
+		for _, subnet := range subnets {
+			if !network.IsIPv6CIDR(subnet) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasIPv6 reports whether any pod or service subnet is IPv6.
+func (cfg *ClusterConfiguration) HasIPv6() bool {
+	for _, cidrs := range []string{cfg.Networking.PodSubnet, cfg.Networking.ServiceSubnet} {
+		subnets, err := network.ParseCIDRs(cidrs)
+		if err != nil {
+			continue
+		}
+		for _, subnet := range subnets {
+			if network.IsIPv6CIDR(subnet) {
+				return true
+			}
+		}
+	}
+	return network.IsIPv6(net.ParseIP(cfg.AdvertiseAddress))
+}
+
+func validateResourceRequests(requests ResourceRequests) error {
+	for _, quantity := range []string{requests.CPU, requests.Memory} {
+		if quantity == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(quantity); err != nil {
+			return fmt.Errorf("%q: %w", quantity, err)
+		}
+	}
+	return nil
+}
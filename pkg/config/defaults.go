@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/network"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Default resource requests, matching the ones kubeadm sets on its static pods.
+const (
+	DefaultEtcdCPU              = "100m"
+	DefaultEtcdMemory           = "100Mi"
+	DefaultAPIServerCPU         = "250m"
+	DefaultControllerManagerCPU = "200m"
+	DefaultSchedulerCPU         = "100m"
+)
+
+const DefaultAPIServerPort = 6443
+
+// Algorithms for the keys of CAs, certificates and service account tokens.
+const (
+	EncryptionAlgorithmRSA2048   = "RSA-2048"
+	EncryptionAlgorithmRSA3072   = "RSA-3072"
+	EncryptionAlgorithmRSA4096   = "RSA-4096"
+	EncryptionAlgorithmECDSAP256 = "ECDSA-P256"
+	EncryptionAlgorithmECDSAP384 = "ECDSA-P384"
+
+	DefaultEncryptionAlgorithm = EncryptionAlgorithmRSA2048
+)
+
+var EncryptionAlgorithms = []string{
+	EncryptionAlgorithmRSA2048,
+	EncryptionAlgorithmRSA3072,
+	EncryptionAlgorithmRSA4096,
+	EncryptionAlgorithmECDSAP256,
+	EncryptionAlgorithmECDSAP384,
+}
+
+const (
+	DefaultPodSubnet     = "10.244.0.0/16"
+	DefaultServiceSubnet = "10.96.0.0/16"
+	DefaultDNSDomain     = "cluster.local"
+)
+
+const DefaultClusterName = "kubernetes"
+
+const (
+	DefaultCertificateValidityPeriod   = 365 * 24 * time.Hour
+	DefaultCACertificateValidityPeriod = 10 * 365 * 24 * time.Hour
+)
+
+func SetDefaults(cfg *ClusterConfiguration) {
+	if cfg.ClusterName == "" {
+		cfg.ClusterName = DefaultClusterName
+	}
+	if cfg.Etcd.Resources.CPU == "" {
+		cfg.Etcd.Resources.CPU = DefaultEtcdCPU
+	}
+	if cfg.Etcd.Resources.Memory == "" {
+		cfg.Etcd.Resources.Memory = DefaultEtcdMemory
+	}
+	if cfg.APIServer.Resources.CPU == "" {
+		cfg.APIServer.Resources.CPU = DefaultAPIServerCPU
+	}
+	if cfg.ControllerManager.Resources.CPU == "" {
+		cfg.ControllerManager.Resources.CPU = DefaultControllerManagerCPU
+	}
+	if cfg.Scheduler.Resources.CPU == "" {
+		cfg.Scheduler.Resources.CPU = DefaultSchedulerCPU
+	}
+	if cfg.Networking.PodSubnet == "" {
+		cfg.Networking.PodSubnet = DefaultPodSubnet
+	}
+	if cfg.Networking.ServiceSubnet == "" {
+		cfg.Networking.ServiceSubnet = DefaultServiceSubnet
+	}
+	if cfg.Networking.DNSDomain == "" {
+		cfg.Networking.DNSDomain = DefaultDNSDomain
+	}
+	if cfg.EncryptionAlgorithm == "" {
+		cfg.EncryptionAlgorithm = DefaultEncryptionAlgorithm
+	}
+	if cfg.CertificateValidityPeriod == nil {
+		cfg.CertificateValidityPeriod = &metav1.Duration{Duration: DefaultCertificateValidityPeriod}
+	}
+	if cfg.CACertificateValidityPeriod == nil {
+		cfg.CACertificateValidityPeriod = &metav1.Duration{Duration: DefaultCACertificateValidityPeriod}
+	}
+}
+
+// SetDynamicDefaults fills in defaults that depend on the host, such as the
+// node name and the advertise address when none was given.
+func SetDynamicDefaults(cfg *ClusterConfiguration) error {
+	if cfg.NodeName == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("unable to get hostname, please set --node-name: %w", err)
+		}
+		cfg.NodeName = hostname
+	}
+	if lower := strings.ToLower(cfg.NodeName); lower != cfg.NodeName {
+		fmt.Printf("[config] WARNING: node name %q contains uppercase characters, using %q instead\n", cfg.NodeName, lower)
+		cfg.NodeName = lower
+	}
+
+	if cfg.AdvertiseAddress != "" {
+		return nil
+	}
+
+	ip, iface, err := network.ChooseHostAddress()
+	if err != nil {
+		return fmt.Errorf("unable to detect advertise address, please set --advertise-address: %w", err)
+	}
+	cfg.AdvertiseAddress = ip.String()
+
+	fmt.Printf("[config] Using advertise address %s from interface %s, which holds the default route\n", ip, iface)
+	return nil
+}
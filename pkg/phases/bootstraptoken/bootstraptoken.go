@@ -0,0 +1,185 @@
+package bootstraptoken
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/apiclient"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// NodeBootstrapTokenGroup is the group bootstrap tokens created by
+	// k8sbootstrap authenticate as, in addition to system:bootstrappers.
+	NodeBootstrapTokenGroup = "system:bootstrappers:k8sbootstrap:default-node-token"
+
+	ClusterInfoConfigMapName = "cluster-info"
+	ClusterInfoKubeconfigKey = "kubeconfig"
+
+	tokenIDLength     = 6
+	tokenSecretLength = 16
+	tokenCharset      = "0123456789abcdefghijklmnopqrstuvwxyz"
+)
+
+// Token is a bootstrap token in its "<id>.<secret>" form.
+type Token struct {
+	ID     string
+	Secret string
+}
+
+func (t Token) String() string {
+	return fmt.Sprintf("%s.%s", t.ID, t.Secret)
+}
+
+func (t Token) SecretName() string {
+	return fmt.Sprintf("bootstrap-token-%s", t.ID)
+}
+
+func GenerateToken() (Token, error) {
+	id, err := randomString(tokenIDLength)
+	if err != nil {
+		return Token{}, err
+	}
+	secret, err := randomString(tokenSecretLength)
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{ID: id, Secret: secret}, nil
+}
+
+func ParseToken(token string) (Token, error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || len(id) != tokenIDLength || len(secret) != tokenSecretLength {
+		return Token{}, fmt.Errorf("token must be of the form [a-z0-9]{6}.[a-z0-9]{16}")
+	}
+	return Token{ID: id, Secret: secret}, nil
+}
+
+// CreateToken stores token as a bootstrap token secret that expires after
+// ttl. The token controller in kube-controller-manager deletes it, and
+// anything it owns, once it has expired.
+func CreateToken(client kubernetes.Interface, token Token, ttl time.Duration, description string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      token.SecretName(),
+			Namespace: metav1.NamespaceSystem,
+		},
+		Type: corev1.SecretTypeBootstrapToken,
+		StringData: map[string]string{
+			"description":                    description,
+			"token-id":                       token.ID,
+			"token-secret":                   token.Secret,
+			"expiration":                     time.Now().Add(ttl).UTC().Format(time.RFC3339),
+			"usage-bootstrap-authentication": "true",
+			"usage-bootstrap-signing":        "true",
+			"auth-extra-groups":              NodeBootstrapTokenGroup,
+		},
+	}
+
+	if err := apiclient.CreateOrUpdateSecret(client, secret); err != nil {
+		return nil, err
+	}
+
+	created, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Get(context.TODO(), secret.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bootstrap token secret: %w", err)
+	}
+
+	fmt.Printf("[bootstrap-token] Created bootstrap token %s valid for %s\n", token.ID, ttl)
+	return created, nil
+}
+
+// CreateClusterInfo publishes the API server address and CA bundle in the
+// kube-public cluster-info ConfigMap, readable without authentication, so
+// joining nodes can discover the cluster and pin its CA.
+func CreateClusterInfo(client kubernetes.Interface, server string, caData []byte) error {
+	kubeconfig := clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			"": {
+				Server:                   server,
+				CertificateAuthorityData: caData,
+			},
+		},
+	}
+	kubeconfigBytes, err := clientcmd.Write(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to serialize cluster-info kubeconfig: %w", err)
+	}
+
+	err = apiclient.CreateOrUpdateConfigMap(client, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ClusterInfoConfigMapName,
+			Namespace: metav1.NamespacePublic,
+		},
+		Data: map[string]string{
+			ClusterInfoKubeconfigKey: string(kubeconfigBytes),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = apiclient.CreateOrUpdateRole(client, &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sbootstrap:bootstrap-signer-clusterinfo",
+			Namespace: metav1.NamespacePublic,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				Verbs:         []string{"get"},
+				APIGroups:     []string{""},
+				Resources:     []string{"configmaps"},
+				ResourceNames: []string{ClusterInfoConfigMapName},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = apiclient.CreateOrUpdateRoleBinding(client, &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sbootstrap:bootstrap-signer-clusterinfo",
+			Namespace: metav1.NamespacePublic,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     "k8sbootstrap:bootstrap-signer-clusterinfo",
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind: rbacv1.UserKind,
+				Name: "system:anonymous",
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("[bootstrap-token] Created the cluster-info ConfigMap in kube-public")
+	return nil
+}
+
+func randomString(length int) (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(tokenCharset)))
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate random token: %w", err)
+		}
+		sb.WriteByte(tokenCharset[n.Int64()])
+	}
+	return sb.String(), nil
+}
@@ -1,79 +1,114 @@
 package certificates
 
 import (
-	"crypto/rsa"
+	"crypto"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
 )
 
-func createKubernetesCA() error {
-	caKey, err := NewPrivateKey()
+// createCA writes a self-signed CA to /etc/kubernetes/pki/<name>.crt and
+// <name>.key.
+func createCA(cfg *config.ClusterConfiguration, name string, commonName string) error {
+	caKey, err := NewPrivateKey(cfg.EncryptionAlgorithm)
 	if err != nil {
-		return fmt.Errorf("CA key generation failed: %s", err)
+		return fmt.Errorf("%s key generation failed: %s", name, err)
 	}
 
-	caCertBytes, err := NewCACert(caKey)
+	caCertBytes, err := NewCACert(caKey, commonName, cfg.CACertificateValidityPeriod.Duration)
 	if err != nil {
-		return fmt.Errorf("CA cert generation failed: %s", err)
+		return fmt.Errorf("%s cert generation failed: %s", name, err)
 	}
 
-	err = os.MkdirAll("/etc/kubernetes/pki", 0755)
+	keyPath := fmt.Sprintf("/etc/kubernetes/pki/%s.key", name)
+	certPath := fmt.Sprintf("/etc/kubernetes/pki/%s.crt", name)
+
+	err = os.MkdirAll(filepath.Dir(keyPath), 0755)
 	if err != nil {
-		return fmt.Errorf("Failed to create /etc/kubernetes: %s", err)
+		return fmt.Errorf("Failed to create %s: %s", filepath.Dir(keyPath), err)
 	}
 
-	keyOut, err := os.Create("/etc/kubernetes/pki/ca.key")
+	keyPEM, err := encodePrivateKeyPEM(caKey)
 	if err != nil {
-		return fmt.Errorf("CA key saving failed: %s", err)
+		return fmt.Errorf("%s key encoding failed: %s", name, err)
+	}
+	err = os.WriteFile(keyPath, keyPEM, 0600)
+	if err != nil {
+		return fmt.Errorf("%s key saving failed: %s", name, err)
 	}
-	defer keyOut.Close()
-	pem.Encode(keyOut, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(caKey),
-	})
-	os.Chmod("/etc/kubernetes/pki/ca.key", 0600)
 
-	crtOut, err := os.Create("/etc/kubernetes/pki/ca.crt")
+	crtOut, err := os.Create(certPath)
 	if err != nil {
-		return fmt.Errorf("CA cert saving failed: %s", err)
+		return fmt.Errorf("%s cert saving failed: %s", name, err)
 	}
 	defer crtOut.Close()
 	pem.Encode(crtOut, &pem.Block{
 		Type:  "CERTIFICATE",
 		Bytes: caCertBytes,
 	})
-	os.Chmod("/etc/kubernetes/pki/ca.crt", 0600)
+	os.Chmod(certPath, 0600)
 
-	fmt.Println("[certificate] CA certificate successfully generated")
+	fmt.Printf("[certificate] %s certificate successfully generated\n", commonName)
 
 	return nil
 }
 
-func loadCA() (*x509.Certificate, *rsa.PrivateKey, error) {
-	caCertPEM, err := ioutil.ReadFile("/etc/kubernetes/pki/ca.crt")
+func loadCA(name string) (*x509.Certificate, crypto.Signer, error) {
+	caCertPEM, err := os.ReadFile(fmt.Sprintf("/etc/kubernetes/pki/%s.crt", name))
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load CA cert: %w", err)
+		return nil, nil, fmt.Errorf("failed to load %s cert: %w", name, err)
 	}
 
 	block, _ := pem.Decode(caCertPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, nil, fmt.Errorf("failed to parse %s cert: no PEM certificate found", name)
+	}
 	caCert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse CA cert: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse %s cert: %w", name, err)
 	}
 
-	caKeyPEM, err := ioutil.ReadFile("/etc/kubernetes/pki/ca.key")
+	caKeyPEM, err := os.ReadFile(fmt.Sprintf("/etc/kubernetes/pki/%s.key", name))
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load CA key: %w", err)
+		return nil, nil, fmt.Errorf("failed to load %s key: %w", name, err)
 	}
 
-	block, _ = pem.Decode(caKeyPEM)
-	caKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	caKey, err := parsePrivateKeyPEM(caKeyPEM)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse CA key: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse %s key: %w", name, err)
 	}
 
 	return caCert, caKey, nil
 }
+
+// CACertHash returns the "sha256:<hex>" pin of the cluster CA public key,
+// which joining nodes pass as --discovery-token-ca-cert-hash.
+func CACertHash() (string, error) {
+	caCertPEM, err := ioutil.ReadFile("/etc/kubernetes/pki/ca.crt")
+	if err != nil {
+		return "", fmt.Errorf("failed to load CA cert: %w", err)
+	}
+
+	block, _ := pem.Decode(caCertPEM)
+	if block == nil {
+		return "", fmt.Errorf("failed to decode CA cert")
+	}
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse CA cert: %w", err)
+	}
+
+	return PublicKeyHash(caCert), nil
+}
+
+func PublicKeyHash(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
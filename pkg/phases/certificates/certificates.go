@@ -5,109 +5,370 @@ import (
 	"fmt"
 	"net"
 	"os"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/network"
 )
 
-func SetupCerts(advertiseAddress string) error {
-	err := createKubernetesCA()
-	if err != nil {
-		return err
+// SharedCertificate is a file every control plane node must have in common.
+// Name identifies it independently of its path, e.g. as a Secret key.
+type SharedCertificate struct {
+	Name string
+	Path string
+}
+
+// SharedCertificates returns the files that are created by init and copied
+// to nodes joining the control plane.
+func SharedCertificates(cfg *config.ClusterConfiguration) []SharedCertificate {
+	shared := sharedCA("ca", "ca")
+	shared = append(shared,
+		SharedCertificate{"sa.key", "/etc/kubernetes/pki/sa.key"},
+		SharedCertificate{"sa.pub", "/etc/kubernetes/pki/sa.pub"},
+	)
+	shared = append(shared, sharedCA("front-proxy-ca", "front-proxy-ca")...)
+
+	if cfg.Etcd.External == nil {
+		shared = append(shared, sharedCA("etcd-ca", "etcd/ca")...)
+	}
+
+	// An external etcd reached over http has no certificates.
+	if external := cfg.Etcd.External; external != nil {
+		for _, file := range []SharedCertificate{
+			{"external-etcd-ca.crt", external.CAFile},
+			{"external-etcd.crt", external.CertFile},
+			{"external-etcd.key", external.KeyFile},
+		} {
+			if file.Path != "" {
+				shared = append(shared, file)
+			}
+		}
+	}
+	return shared
+}
+
+// sharedCA returns the certificate of the CA stored as
+// /etc/kubernetes/pki/<name>.crt and, unless the CA is external, its key.
+func sharedCA(secretName, name string) []SharedCertificate {
+	shared := []SharedCertificate{
+		{secretName + ".crt", fmt.Sprintf("/etc/kubernetes/pki/%s.crt", name)},
+	}
+	if !IsExternalCA(name) {
+		shared = append(shared, SharedCertificate{secretName + ".key", fmt.Sprintf("/etc/kubernetes/pki/%s.key", name)})
+	}
+	return shared
+}
+
+// CertificateAuthority is a CA stored as /etc/kubernetes/pki/<Name>.crt.
+type CertificateAuthority struct {
+	Name       string
+	CommonName string
+}
+
+// Certificate is a leaf certificate stored as /etc/kubernetes/pki/<Name>.crt
+// and signed by the CA named CAName.
+type Certificate struct {
+	Name   string
+	CAName string
+	Opts   CertOpts
+}
+
+// ClusterAdminsGroup is the group of the admin.conf user. It is granted
+// cluster-admin by a ClusterRoleBinding, which unlike system:masters can be
+// removed to revoke it.
+const ClusterAdminsGroup = "k8sbootstrap:cluster-admins"
+
+// SuperAdminCertificate is the break-glass identity of super-admin.conf. It
+// is in system:masters, which bypasses RBAC, and is only issued by init.
+var SuperAdminCertificate = Certificate{
+	Name:   "super-admin",
+	CAName: "ca",
+	Opts: CertOpts{
+		CommonName:   "kubernetes-super-admin",
+		Organization: []string{"system:masters"},
+	},
+}
+
+// CertificateAuthorities returns the CAs needed for cfg.
+func CertificateAuthorities(cfg *config.ClusterConfiguration) []CertificateAuthority {
+	cas := []CertificateAuthority{
+		{Name: "ca", CommonName: "kubernetes-ca"},
+		{Name: "front-proxy-ca", CommonName: "front-proxy-ca"},
+	}
+
+	// An external etcd cluster comes with its own certificates.
+	if cfg.Etcd.External == nil {
+		cas = append(cas, CertificateAuthority{Name: "etcd/ca", CommonName: "etcd-ca"})
 	}
+	return cas
+}
+
+// NodeCertificates returns the leaf certificates that are specific to this
+// control plane node.
+func NodeCertificates(cfg *config.ClusterConfiguration) ([]Certificate, error) {
+	advertiseAddress := cfg.AdvertiseAddress
 
-	hostname, err := os.Hostname()
+	kubernetesServiceIP, err := network.APIServerVirtualIP(cfg.Networking.ServiceSubnet)
 	if err != nil {
-		return fmt.Errorf("failed to get hostname: %w", err)
+		return nil, fmt.Errorf("failed to get kubernetes service IP: %w", err)
 	}
 
 	kubeApiserverIPs := []net.IP{
 		net.IPv4(127, 0, 0, 1),
-		net.IPv4(10, 96, 0, 1), //default pod CIDR IP of kubeadm
+		net.IPv6loopback,
+		kubernetesServiceIP,
 		net.ParseIP(advertiseAddress),
 	}
 	kubeApiserverDNS := []string{
 		"kubernetes",
 		"kubernetes.default",
 		"kubernetes.default.svc",
-		"kubernetes.default.svc.cluster.local",
-		hostname,
-	}
-	err = createCertificate(
-		"kube-apiserver",
-		CertOpts{
-			CommonName: "kube-apiserver",
-			IPs:        kubeApiserverIPs,
-			DNSNames:   kubeApiserverDNS,
-			ExtKeyUsage: []x509.ExtKeyUsage{
-				x509.ExtKeyUsageServerAuth,
+		fmt.Sprintf("kubernetes.default.svc.%s", cfg.Networking.DNSDomain),
+		cfg.NodeName,
+	}
+	if cfg.ControlPlaneEndpoint != "" {
+		endpointHost, _, err := cfg.GetControlPlaneEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(endpointHost); ip != nil {
+			kubeApiserverIPs = append(kubeApiserverIPs, ip)
+		} else {
+			kubeApiserverDNS = append(kubeApiserverDNS, endpointHost)
+		}
+	}
+	for _, san := range cfg.APIServer.CertSANs {
+		if ip := net.ParseIP(san); ip != nil {
+			kubeApiserverIPs = append(kubeApiserverIPs, ip)
+		} else {
+			kubeApiserverDNS = append(kubeApiserverDNS, san)
+		}
+	}
+
+	certs := []Certificate{
+		{
+			Name:   "apiserver",
+			CAName: "ca",
+			Opts: CertOpts{
+				CommonName: "kube-apiserver",
+				IPs:        uniqueIPs(kubeApiserverIPs),
+				DNSNames:   uniqueDNSNames(kubeApiserverDNS),
+				ExtKeyUsage: []x509.ExtKeyUsage{
+					x509.ExtKeyUsageServerAuth,
+				},
+				IsServerCert: true,
+			},
+		},
+		{
+			Name:   "apiserver-kubelet-client",
+			CAName: "ca",
+			Opts: CertOpts{
+				CommonName:   "apiserver-kubelet-client",
+				Organization: []string{"system:masters"},
+			},
+		},
+		{
+			Name:   "controller-manager",
+			CAName: "ca",
+			Opts: CertOpts{
+				CommonName: "system:kube-controller-manager",
+			},
+		},
+		{
+			Name:   "scheduler",
+			CAName: "ca",
+			Opts: CertOpts{
+				CommonName: "system:kube-scheduler",
+			},
+		},
+		{
+			Name:   "admin",
+			CAName: "ca",
+			Opts: CertOpts{
+				CommonName:   "kubernetes-admin",
+				Organization: []string{ClusterAdminsGroup},
+			},
+		},
+		{
+			Name:   "front-proxy-client",
+			CAName: "front-proxy-ca",
+			Opts: CertOpts{
+				CommonName: "front-proxy-client",
 			},
-			IsServerCert: true,
 		},
-	)
-	if err != nil {
-		return err
 	}
 
-	err = createCertificate("apiserver-kubelet-client", CertOpts{
-		CommonName:   "apiserver-kubelet-client",
-		Organization: []string{"system:masters"},
-	})
-	if err != nil {
-		return err
+	if cfg.Etcd.External == nil {
+		certs = append(certs, etcdCertificates(advertiseAddress, cfg.NodeName)...)
 	}
+	return certs, nil
+}
 
-	err = createCertificate("apiserver-etcd-client", CertOpts{
-		CommonName:   "kube-apiserver-etcd-client",
-		Organization: []string{"system:masters"},
-	})
-	if err != nil {
-		return err
+func etcdCertificates(advertiseAddress string, nodeName string) []Certificate {
+	etcdIPs := []net.IP{
+		net.ParseIP("127.0.0.1"),
+		net.IPv6loopback,
+		net.ParseIP(advertiseAddress),
+	}
+	etcdDNS := []string{
+		"localhost",
+		nodeName,
 	}
 
-	err = createCertificate("controller-manager", CertOpts{
-		CommonName: "system:kube-controller-manager",
-	})
-	if err != nil {
-		return err
+	return []Certificate{
+		{
+			Name:   "apiserver-etcd-client",
+			CAName: "etcd/ca",
+			Opts: CertOpts{
+				CommonName:   "kube-apiserver-etcd-client",
+				Organization: []string{"system:masters"},
+			},
+		},
+		{
+			Name:   "etcd/server",
+			CAName: "etcd/ca",
+			Opts: CertOpts{
+				CommonName: nodeName,
+				IPs:        etcdIPs,
+				DNSNames:   etcdDNS,
+				ExtKeyUsage: []x509.ExtKeyUsage{
+					x509.ExtKeyUsageServerAuth,
+					x509.ExtKeyUsageClientAuth,
+				},
+				IsServerCert: true,
+			},
+		},
+		{
+			Name:   "etcd/peer",
+			CAName: "etcd/ca",
+			Opts: CertOpts{
+				CommonName: nodeName,
+				IPs:        etcdIPs,
+				DNSNames:   etcdDNS,
+				ExtKeyUsage: []x509.ExtKeyUsage{
+					x509.ExtKeyUsageServerAuth,
+					x509.ExtKeyUsageClientAuth,
+				},
+				IsServerCert: true,
+			},
+		},
+		{
+			Name:   "etcd/healthcheck-client",
+			CAName: "etcd/ca",
+			Opts: CertOpts{
+				CommonName: "kube-etcd-healthcheck-client",
+			},
+		},
 	}
+}
+
+// SetupCerts creates the CAs, the node certificates and the service account
+// keys. Existing files are reused after validation, so running it again
+// leaves a working PKI untouched and fails instead of overwriting files that
+// do not fit the configuration. A CA whose certificate is present without its
+// key is treated as external: nothing is signed with it, and the
+// certificates it should have signed must already be in place.
+func SetupCerts(cfg *config.ClusterConfiguration) error {
+	for _, ca := range CertificateAuthorities(cfg) {
+		crtPath := fmt.Sprintf("/etc/kubernetes/pki/%s.crt", ca.Name)
+		keyPath := fmt.Sprintf("/etc/kubernetes/pki/%s.key", ca.Name)
 
-	err = createCertificate("scheduler", CertOpts{
-		CommonName: "system:kube-scheduler",
-	})
+		switch {
+		case IsExternalCA(ca.Name):
+			fmt.Printf("[certificate] Using external %s, %s.key not found\n", ca.CommonName, ca.Name)
+		case fileExists(crtPath):
+			if err := validateCA(ca); err != nil {
+				return fmt.Errorf("existing %s cannot be reused: %w", ca.CommonName, err)
+			}
+			fmt.Printf("[certificate] Using existing %s\n", ca.CommonName)
+		case fileExists(keyPath):
+			return fmt.Errorf("found %s without %s, remove it or provide the certificate", keyPath, crtPath)
+		default:
+			if err := createCA(cfg, ca.Name, ca.CommonName); err != nil {
+				return err
+			}
+		}
+	}
+
+	err := SetupNodeCerts(cfg)
 	if err != nil {
 		return err
 	}
 
-	err = createCertificate("admin", CertOpts{
-		CommonName:   "kubernetes-admin",
-		Organization: []string{"system:masters"},
-	})
+	err = setupCertificate(cfg, SuperAdminCertificate)
 	if err != nil {
 		return err
 	}
 
-	err = createCertificate("etcd-server", CertOpts{
-		CommonName: "etcd-server",
-		IPs: []net.IP{
-			net.ParseIP("127.0.0.1"),
-			net.ParseIP(advertiseAddress),
-		},
-		DNSNames: []string{
-			"localhost",
-			hostname,
-		},
-		ExtKeyUsage: []x509.ExtKeyUsage{
-			x509.ExtKeyUsageServerAuth,
-			x509.ExtKeyUsageClientAuth,
-		},
-		IsServerCert: true,
-	})
+	if fileExists("/etc/kubernetes/pki/sa.key") {
+		if err := validateServiceAccountKeys(); err != nil {
+			return fmt.Errorf("existing service account keys cannot be reused: %w", err)
+		}
+		fmt.Println("[certificate] Using existing service account keys")
+		return nil
+	}
+
+	err = createServiceAccountKeys(cfg)
 	if err != nil {
 		return err
 	}
 
-	err = createServiceAccountKeys()
+	return nil
+}
+
+// CheckSharedCertificates verifies that the cluster-wide CAs and service
+// account keys are present before a node joins the control plane.
+func CheckSharedCertificates(cfg *config.ClusterConfiguration) error {
+	var missing []string
+	for _, shared := range SharedCertificates(cfg) {
+		if _, err := os.Stat(shared.Path); err != nil {
+			missing = append(missing, shared.Path)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing shared certificates %v, copy them from an existing control plane node", missing)
+	}
+	return nil
+}
+
+// SetupNodeCerts creates the certificates that are specific to this control
+// plane node, signed by the existing cluster, front proxy and etcd CAs.
+// Existing certificates and certificates of external CAs are validated
+// instead.
+func SetupNodeCerts(cfg *config.ClusterConfiguration) error {
+	certs, err := NodeCertificates(cfg)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	for _, cert := range certs {
+		err = setupCertificate(cfg, cert)
+		if err != nil {
+			return err
+		}
+	}
+
+	return SetupKubeletClientCert(cfg)
+}
+
+func setupCertificate(cfg *config.ClusterConfiguration, cert Certificate) error {
+	if IsExternalCA(cert.CAName) {
+		err := validateCertificate(cert)
+		if err != nil {
+			return fmt.Errorf("%s is not valid for external %s: %w", cert.Name, cert.CAName, err)
+		}
+		fmt.Printf("[certificate] Using existing %s certificate signed by external %s\n", cert.Name, cert.CAName)
+		return nil
+	}
+
+	if fileExists(fmt.Sprintf("/etc/kubernetes/pki/%s.crt", cert.Name)) {
+		err := validateCertificate(cert)
+		if err != nil {
+			return fmt.Errorf("existing %s certificate cannot be reused, remove it to have it regenerated: %w", cert.Name, err)
+		}
+		fmt.Printf("[certificate] Using existing %s certificate\n", cert.Name)
+		return nil
+	}
+
+	return createCertificate(cfg, cert.Name, cert.CAName, cert.Opts)
 }
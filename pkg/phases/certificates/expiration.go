@@ -0,0 +1,192 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// CertificateExpiration describes the validity of a certificate found in the
+// PKI directory or in a kubeconfig.
+type CertificateExpiration struct {
+	Name                   string    `json:"name"`
+	Path                   string    `json:"path"`
+	Expires                time.Time `json:"expires"`
+	ResidualTime           string    `json:"residualTime"`
+	CertificateAuthority   string    `json:"certificateAuthority,omitempty"`
+	IsCertificateAuthority bool      `json:"isCertificateAuthority"`
+	ExternallyManaged      bool      `json:"externallyManaged"`
+}
+
+// CheckExpiration returns the expiration of every certificate under
+// /etc/kubernetes/pki, the kubelet client certificate and every client
+// certificate used by the kubeconfigs in /etc/kubernetes, sorted by name.
+func CheckExpiration(now time.Time) ([]CertificateExpiration, error) {
+	pkiCerts, err := loadPKICertificates()
+	if err != nil {
+		return nil, err
+	}
+	cas := certificateAuthorities(pkiCerts)
+
+	var expirations []CertificateExpiration
+	for name, cert := range pkiCerts {
+		expiration := newCertificateExpiration(name, fmt.Sprintf("/etc/kubernetes/pki/%s.crt", name), cert, cas, now)
+		if cert.IsCA {
+			expiration.ExternallyManaged = IsExternalCA(name)
+		}
+		expirations = append(expirations, expiration)
+	}
+
+	kubeletCert, _, err := kubeletClientCertSigner(cas)
+	if err != nil {
+		return nil, err
+	}
+	if kubeletCert != nil {
+		expirations = append(expirations, newCertificateExpiration(KubeletClientCertName, KubeletClientCertPath, kubeletCert, cas, now))
+	}
+
+	kubeconfigs, err := filepath.Glob("/etc/kubernetes/*.conf")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range kubeconfigs {
+		kubeconfigExpirations, err := kubeconfigCertificates(path, cas, now)
+		if err != nil {
+			return nil, err
+		}
+		expirations = append(expirations, kubeconfigExpirations...)
+	}
+
+	sort.Slice(expirations, func(i, j int) bool {
+		return expirations[i].Name < expirations[j].Name
+	})
+	return expirations, nil
+}
+
+// kubeconfigCertificates returns the client certificates of the kubeconfig at
+// path, whether embedded or referenced by file.
+func kubeconfigCertificates(path string, cas map[string]*x509.Certificate, now time.Time) ([]CertificateExpiration, error) {
+	kubeconfig, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+
+	var expirations []CertificateExpiration
+	for user, authInfo := range kubeconfig.AuthInfos {
+		certPEM := authInfo.ClientCertificateData
+		certPath := path
+		// The kubelet client certificate is listed on its own.
+		if authInfo.ClientCertificate == KubeletClientCertPath {
+			continue
+		}
+		if len(certPEM) == 0 && authInfo.ClientCertificate != "" {
+			certPath = authInfo.ClientCertificate
+			certPEM, err = os.ReadFile(certPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client cert of %s in %s: %w", user, path, err)
+			}
+		}
+		if len(certPEM) == 0 {
+			continue
+		}
+
+		block, _ := pem.Decode(certPEM)
+		if block == nil {
+			return nil, fmt.Errorf("failed to decode client cert of %s in %s", user, path)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse client cert of %s in %s: %w", user, path, err)
+		}
+
+		expirations = append(expirations, newCertificateExpiration(filepath.Base(path), certPath, cert, cas, now))
+	}
+	return expirations, nil
+}
+
+func newCertificateExpiration(name string, path string, cert *x509.Certificate, cas map[string]*x509.Certificate, now time.Time) CertificateExpiration {
+	expiration := CertificateExpiration{
+		Name:                   name,
+		Path:                   path,
+		Expires:                cert.NotAfter,
+		ResidualTime:           FormatResidualTime(cert.NotAfter.Sub(now)),
+		IsCertificateAuthority: cert.IsCA,
+	}
+
+	if caName := issuingCA(name, cert, cas); caName != "" {
+		expiration.CertificateAuthority = caName
+		expiration.ExternallyManaged = IsExternalCA(caName)
+	}
+	return expiration
+}
+
+// loadPKICertificates returns every certificate under /etc/kubernetes/pki,
+// keyed by its name relative to that directory without the .crt extension.
+func loadPKICertificates() (map[string]*x509.Certificate, error) {
+	pkiCerts := map[string]*x509.Certificate{}
+	err := filepath.WalkDir("/etc/kubernetes/pki", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".crt" {
+			return nil
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/etc/kubernetes/pki/"), ".crt")
+		cert, err := LoadCertificate(name)
+		if err != nil {
+			return err
+		}
+		pkiCerts[name] = cert
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /etc/kubernetes/pki: %w", err)
+	}
+	return pkiCerts, nil
+}
+
+func certificateAuthorities(pkiCerts map[string]*x509.Certificate) map[string]*x509.Certificate {
+	cas := map[string]*x509.Certificate{}
+	for name, cert := range pkiCerts {
+		if cert.IsCA {
+			cas[name] = cert
+		}
+	}
+	return cas
+}
+
+// issuingCA returns the name of the CA in cas that signed cert, or "" if none
+// did.
+func issuingCA(name string, cert *x509.Certificate, cas map[string]*x509.Certificate) string {
+	for caName, ca := range cas {
+		if caName != name && cert.CheckSignatureFrom(ca) == nil {
+			return caName
+		}
+	}
+	return ""
+}
+
+// FormatResidualTime renders a duration as e.g. "364d" or "23h", and
+// "<invalid>" once the certificate has expired.
+func FormatResidualTime(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "<invalid>"
+	case d >= 365*24*time.Hour:
+		return fmt.Sprintf("%dy", int(d.Hours()/24/365))
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
@@ -0,0 +1,118 @@
+package certificates
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+)
+
+// IsExternalCA reports whether the CA stored as /etc/kubernetes/pki/<name>.crt
+// is managed outside the cluster, i.e. its certificate is present but its key
+// is not.
+func IsExternalCA(name string) bool {
+	if _, err := os.Stat(fmt.Sprintf("/etc/kubernetes/pki/%s.crt", name)); err != nil {
+		return false
+	}
+	_, err := os.Stat(fmt.Sprintf("/etc/kubernetes/pki/%s.key", name))
+	return os.IsNotExist(err)
+}
+
+// GenerateCSRs writes a private key and a certificate signing request for
+// every node certificate, the kubelet client certificate and the super-admin
+// certificate issued by init, to be signed by an external CA. Certificates
+// whose key or signed certificate already exists are skipped unless overwrite
+// is set, so that running it again does not invalidate signed certificates.
+func GenerateCSRs(cfg *config.ClusterConfiguration, overwrite bool) error {
+	certs, err := NodeCertificates(cfg)
+	if err != nil {
+		return err
+	}
+	certs = append(certs, SuperAdminCertificate)
+
+	for _, cert := range certs {
+		err = createCSR(cfg, cert,
+			fmt.Sprintf("/etc/kubernetes/pki/%s.key", cert.Name),
+			fmt.Sprintf("/etc/kubernetes/pki/%s.csr", cert.Name),
+			fmt.Sprintf("/etc/kubernetes/pki/%s.crt", cert.Name),
+			overwrite,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	kubeletCert := Certificate{Name: KubeletClientCertName, CAName: "ca", Opts: KubeletCertOpts(cfg.NodeName)}
+	return createCSR(cfg, kubeletCert, kubeletClientKeyPath, kubeletClientCSRPath, kubeletClientCrtPath, overwrite)
+}
+
+func createCSR(cfg *config.ClusterConfiguration, cert Certificate, keyPath string, csrPath string, crtPath string, overwrite bool) error {
+	if !overwrite {
+		for _, path := range []string{keyPath, crtPath} {
+			if fileExists(path) {
+				fmt.Printf("[certificate] Skipping %s, %s already exists, use --overwrite to replace it\n", cert.Name, path)
+				return nil
+			}
+		}
+	}
+
+	// A certificate signed for the replaced key would no longer match it.
+	err := os.Remove(crtPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", crtPath, err)
+	}
+
+	privKey, err := NewPrivateKey(cfg.EncryptionAlgorithm)
+	if err != nil {
+		return fmt.Errorf("%s key generation failed: %s", cert.Name, err)
+	}
+
+	var ips []net.IP
+	var dnsNames []string
+	if cert.Opts.IsServerCert {
+		ips = cert.Opts.IPs
+		dnsNames = cert.Opts.DNSNames
+	}
+
+	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   cert.Opts.CommonName,
+			Organization: cert.Opts.Organization,
+		},
+		IPAddresses: ips,
+		DNSNames:    dnsNames,
+	}, privKey)
+	if err != nil {
+		return fmt.Errorf("%s CSR generation failed: %s", cert.Name, err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(keyPath), 0755)
+	if err != nil {
+		return fmt.Errorf("%s certificate directory creation failed: %s", cert.Name, err)
+	}
+
+	keyPEM, err := encodePrivateKeyPEM(privKey)
+	if err != nil {
+		return fmt.Errorf("%s key encoding failed: %s", cert.Name, err)
+	}
+	err = os.WriteFile(keyPath, keyPEM, 0600)
+	if err != nil {
+		return fmt.Errorf("%s key saving failed: %s", cert.Name, err)
+	}
+
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes})
+	err = os.WriteFile(csrPath, csrPEM, 0644)
+	if err != nil {
+		return fmt.Errorf("%s CSR saving failed: %s", cert.Name, err)
+	}
+
+	fmt.Printf("[certificate] %s key and CSR written to %s\n", cert.Name, csrPath)
+
+	return nil
+}
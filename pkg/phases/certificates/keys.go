@@ -0,0 +1,87 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+)
+
+func NewPrivateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case config.EncryptionAlgorithmRSA2048, "":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case config.EncryptionAlgorithmRSA3072:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case config.EncryptionAlgorithmRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case config.EncryptionAlgorithmECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case config.EncryptionAlgorithmECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	}
+	return nil, fmt.Errorf("unsupported encryption algorithm %q", algorithm)
+}
+
+// encodePrivateKeyPEM writes keys of any algorithm as PKCS#8.
+func encodePrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// parsePrivateKeyPEM reads PKCS#8 keys as well as the PKCS#1 RSA and SEC 1
+// EC keys written by earlier versions.
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+}
+
+// leafKeyUsage returns the key usages for a leaf certificate. Key
+// encipherment only applies to RSA keys.
+func leafKeyUsage(key crypto.Signer) x509.KeyUsage {
+	usage := x509.KeyUsageDigitalSignature
+	if _, ok := key.Public().(*rsa.PublicKey); ok {
+		usage |= x509.KeyUsageKeyEncipherment
+	}
+	return usage
+}
+
+// newPrivateKeyLike generates a key of the same algorithm and size as pub.
+func newPrivateKeyLike(pub crypto.PublicKey) (crypto.Signer, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.GenerateKey(rand.Reader, pub.N.BitLen())
+	case *ecdsa.PublicKey:
+		return ecdsa.GenerateKey(pub.Curve, rand.Reader)
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", pub)
+}
@@ -0,0 +1,267 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+)
+
+// KubeletClientCertPath is the cert and key the kubelet uses to authenticate
+// to the API server. It is a symlink to a timestamped file, the layout the
+// kubelet itself uses when it rotates its client certificate.
+const KubeletClientCertPath = "/var/lib/kubelet/pki/kubelet-client-current.pem"
+
+// KubeletClientCertName identifies the kubelet client certificate in renew,
+// rotate-ca and check-expiration, next to the names of the certificates
+// under /etc/kubernetes/pki.
+const KubeletClientCertName = "kubelet-client"
+
+// With an external CA, the kubelet client certificate signed for the CSR
+// written by GenerateCSRs is expected next to its key.
+const (
+	kubeletClientKeyPath = "/var/lib/kubelet/pki/kubelet-client.key"
+	kubeletClientCSRPath = "/var/lib/kubelet/pki/kubelet-client.csr"
+	kubeletClientCrtPath = "/var/lib/kubelet/pki/kubelet-client.crt"
+)
+
+// KubeletCertOpts returns the identity of the kubelet on nodeName, as expected
+// by the Node authorizer.
+func KubeletCertOpts(nodeName string) CertOpts {
+	return CertOpts{
+		CommonName:   fmt.Sprintf("system:node:%s", nodeName),
+		Organization: []string{"system:nodes"},
+	}
+}
+
+// SetupKubeletClientCert issues the kubelet client certificate signed by the
+// cluster CA, or validates the existing one. With an external CA, the
+// certificate signed for the kubelet CSR is installed instead.
+func SetupKubeletClientCert(cfg *config.ClusterConfiguration) error {
+	certOpts := KubeletCertOpts(cfg.NodeName)
+
+	if fileExists(KubeletClientCertPath) {
+		err := validateKubeletClientCert(certOpts)
+		if err != nil {
+			return fmt.Errorf("existing kubelet client certificate cannot be reused, remove %s to have it regenerated: %w", KubeletClientCertPath, err)
+		}
+		fmt.Println("[certificate] Using existing kubelet client certificate")
+		return nil
+	}
+
+	if IsExternalCA("ca") {
+		return installExternalKubeletClientCert(certOpts)
+	}
+
+	privKey, err := NewPrivateKey(cfg.EncryptionAlgorithm)
+	if err != nil {
+		return fmt.Errorf("kubelet client key generation failed: %s", err)
+	}
+
+	caCert, caKey, err := loadCA("ca")
+	if err != nil {
+		return fmt.Errorf("kubelet client key generation failed: %s", err)
+	}
+
+	certBytes, err := NewClientCert(privKey, certOpts, caCert, caKey, cfg.CertificateValidityPeriod.Duration)
+	if err != nil {
+		return fmt.Errorf("kubelet client cert generation failed: %s", err)
+	}
+
+	err = writeKubeletClientCert(certBytes, privKey)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[certificate] kubelet client certificate for %s successfully generated\n", certOpts.CommonName)
+	return nil
+}
+
+// installExternalKubeletClientCert installs the certificate an external CA
+// signed for the kubelet CSR, once it is validated.
+func installExternalKubeletClientCert(certOpts CertOpts) error {
+	if !fileExists(kubeletClientCrtPath) || !fileExists(kubeletClientKeyPath) {
+		return fmt.Errorf("the cluster CA is external, sign %s for %s and place it as %s, or provide %s",
+			kubeletClientCSRPath, certOpts.CommonName, kubeletClientCrtPath, KubeletClientCertPath)
+	}
+
+	certPEM, err := os.ReadFile(kubeletClientCrtPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", kubeletClientCrtPath, err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("no certificate found in %s", kubeletClientCrtPath)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", kubeletClientCrtPath, err)
+	}
+
+	keyPEM, err := os.ReadFile(kubeletClientKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", kubeletClientKeyPath, err)
+	}
+	key, err := parsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", kubeletClientKeyPath, err)
+	}
+
+	err = checkKubeletClientCert(cert, key, certOpts)
+	if err != nil {
+		return fmt.Errorf("%s is not valid for external ca: %w", kubeletClientCrtPath, err)
+	}
+
+	err = writeKubeletClientCert(cert.Raw, key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[certificate] Using kubelet client certificate for %s signed by external ca\n", certOpts.CommonName)
+	return nil
+}
+
+// loadKubeletClientCert returns the kubelet client certificate and its key.
+func loadKubeletClientCert() (*x509.Certificate, crypto.Signer, error) {
+	data, err := os.ReadFile(KubeletClientCertPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, rest := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, nil, fmt.Errorf("no certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := parsePrivateKeyPEM(rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// writeKubeletClientCert stores the certificate and its key in a new
+// timestamped file and points KubeletClientCertPath at it.
+func writeKubeletClientCert(certBytes []byte, key crypto.Signer) error {
+	keyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		return fmt.Errorf("kubelet client key encoding failed: %s", err)
+	}
+
+	dir := filepath.Dir(KubeletClientCertPath)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	pemPath := filepath.Join(dir, fmt.Sprintf("kubelet-client-%s.pem", timeNow().Format("2006-01-02-15-04-05")))
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), keyPEM...)
+	err = os.WriteFile(pemPath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("kubelet client cert saving failed: %s", err)
+	}
+
+	// Replace the link atomically, the kubelet may be reading it.
+	tmpLink := KubeletClientCertPath + ".tmp"
+	os.Remove(tmpLink)
+	err = os.Symlink(filepath.Base(pemPath), tmpLink)
+	if err == nil {
+		err = os.Rename(tmpLink, KubeletClientCertPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to link %s: %w", KubeletClientCertPath, err)
+	}
+	return nil
+}
+
+// kubeletClientCertSigner returns the kubelet client certificate and the name
+// of the CA in cas that signed it, or nil if there is no such certificate.
+func kubeletClientCertSigner(cas map[string]*x509.Certificate) (*x509.Certificate, string, error) {
+	if !fileExists(KubeletClientCertPath) {
+		return nil, "", nil
+	}
+	cert, _, err := loadKubeletClientCert()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load %s: %w", KubeletClientCertPath, err)
+	}
+	return cert, issuingCA(KubeletClientCertName, cert, cas), nil
+}
+
+// renewKubeletClientCert re-signs the kubelet client certificate with the CA
+// named caName, keeping its subject.
+func renewKubeletClientCert(cert *x509.Certificate, caName string, reuseKey bool, validity time.Duration) error {
+	_, key, err := loadKubeletClientCert()
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", KubeletClientCertPath, err)
+	}
+	if !reuseKey {
+		key, err = newPrivateKeyLike(cert.PublicKey)
+		if err != nil {
+			return fmt.Errorf("kubelet client key generation failed: %s", err)
+		}
+	}
+
+	caCert, caKey, err := loadCA(caName)
+	if err != nil {
+		return err
+	}
+
+	certBytes, err := renewCertificate(cert, key, caCert, caKey, validity)
+	if err != nil {
+		return fmt.Errorf("kubelet client cert generation failed: %s", err)
+	}
+
+	err = writeKubeletClientCert(certBytes, key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[certificate] %s certificate renewed\n", KubeletClientCertName)
+	return nil
+}
+
+func validateKubeletClientCert(certOpts CertOpts) error {
+	cert, key, err := loadKubeletClientCert()
+	if err != nil {
+		return err
+	}
+	return checkKubeletClientCert(cert, key, certOpts)
+}
+
+func checkKubeletClientCert(cert *x509.Certificate, key crypto.Signer, certOpts CertOpts) error {
+	if !keyMatchesCertificate(key, cert) {
+		return fmt.Errorf("key does not match certificate")
+	}
+
+	if cert.Subject.CommonName != certOpts.CommonName {
+		return fmt.Errorf("expected common name %q, got %q", certOpts.CommonName, cert.Subject.CommonName)
+	}
+
+	for _, org := range certOpts.Organization {
+		if !slices.Contains(cert.Subject.Organization, org) {
+			return fmt.Errorf("missing organization %q", org)
+		}
+	}
+
+	caCert, err := LoadCertificate("ca")
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
@@ -0,0 +1,196 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// RenewableCertificates returns the names of the leaf certificates under
+// /etc/kubernetes/pki, and of the kubelet client certificate, whose CA key is
+// available.
+func RenewableCertificates() ([]string, error) {
+	pkiCerts, err := loadPKICertificates()
+	if err != nil {
+		return nil, err
+	}
+	cas := certificateAuthorities(pkiCerts)
+
+	var names []string
+	for name, cert := range pkiCerts {
+		if cert.IsCA {
+			continue
+		}
+		caName := issuingCA(name, cert, cas)
+		if caName == "" || IsExternalCA(caName) {
+			continue
+		}
+		names = append(names, name)
+	}
+
+	kubeletCert, caName, err := kubeletClientCertSigner(cas)
+	if err != nil {
+		return nil, err
+	}
+	if kubeletCert != nil && caName != "" && !IsExternalCA(caName) {
+		names = append(names, KubeletClientCertName)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// RenewCertificate re-signs /etc/kubernetes/pki/<name>.crt with the CA that
+// issued it, keeping its subject, SANs and key usages. Unless reuseKey is
+// set, a new key of the same algorithm is generated. Kubeconfigs embedding
+// the old certificate are updated.
+func RenewCertificate(name string, reuseKey bool, validity time.Duration) error {
+	pkiCerts, err := loadPKICertificates()
+	if err != nil {
+		return err
+	}
+
+	if name == KubeletClientCertName {
+		cert, caName, err := kubeletClientCertSigner(certificateAuthorities(pkiCerts))
+		if err != nil {
+			return err
+		}
+		if cert == nil {
+			return fmt.Errorf("kubelet client certificate %s not found", KubeletClientCertPath)
+		}
+		if caName == "" {
+			return fmt.Errorf("%s is not signed by any CA in /etc/kubernetes/pki", name)
+		}
+		if IsExternalCA(caName) {
+			return fmt.Errorf("%s is signed by external %s and must be renewed by its owner", name, caName)
+		}
+		return renewKubeletClientCert(cert, caName, reuseKey, validity)
+	}
+
+	cert, ok := pkiCerts[name]
+	if !ok {
+		return fmt.Errorf("certificate %s not found in /etc/kubernetes/pki", name)
+	}
+	if cert.IsCA {
+		return fmt.Errorf("%s is a certificate authority and cannot be renewed", name)
+	}
+
+	caName := issuingCA(name, cert, certificateAuthorities(pkiCerts))
+	if caName == "" {
+		return fmt.Errorf("%s is not signed by any CA in /etc/kubernetes/pki", name)
+	}
+	if IsExternalCA(caName) {
+		return fmt.Errorf("%s is signed by external %s and must be renewed by its owner", name, caName)
+	}
+
+	return resignCertificate(name, cert, caName, reuseKey, validity)
+}
+
+// resignCertificate issues a copy of cert, stored as
+// /etc/kubernetes/pki/<name>.crt, signed by the CA named caName.
+func resignCertificate(name string, cert *x509.Certificate, caName string, reuseKey bool, validity time.Duration) error {
+	caCert, caKey, err := loadCA(caName)
+	if err != nil {
+		return err
+	}
+
+	var key crypto.Signer
+	if reuseKey {
+		key, err = loadPrivateKey(name)
+	} else {
+		key, err = newPrivateKeyLike(cert.PublicKey)
+	}
+	if err != nil {
+		return fmt.Errorf("%s key generation failed: %s", name, err)
+	}
+
+	certBytes, err := renewCertificate(cert, key, caCert, caKey, validity)
+	if err != nil {
+		return fmt.Errorf("%s cert generation failed: %s", name, err)
+	}
+
+	newKey := key
+	if reuseKey {
+		newKey = nil
+	}
+	err = writeCertificateAndKey(name, certBytes, newKey)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[certificate] %s certificate renewed\n", name)
+
+	return updateEmbeddedKubeconfigs(cert, certBytes, key)
+}
+
+func renewCertificate(cert *x509.Certificate, key crypto.Signer, caCert *x509.Certificate, caKey crypto.Signer, validity time.Duration) ([]byte, error) {
+	serialNumber, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	notBefore, notAfter := leafValidity(validity, caCert)
+
+	certTemplate := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               cert.Subject,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IPAddresses:           cert.IPAddresses,
+		DNSNames:              cert.DNSNames,
+		KeyUsage:              cert.KeyUsage,
+		ExtKeyUsage:           cert.ExtKeyUsage,
+		BasicConstraintsValid: cert.BasicConstraintsValid,
+	}
+
+	return x509.CreateCertificate(rand.Reader, certTemplate, caCert, key.Public(), caKey)
+}
+
+// updateEmbeddedKubeconfigs replaces oldCert with certBytes and key in every
+// kubeconfig in /etc/kubernetes that embeds it.
+func updateEmbeddedKubeconfigs(oldCert *x509.Certificate, certBytes []byte, key crypto.Signer) error {
+	kubeconfigs, err := filepath.Glob("/etc/kubernetes/*.conf")
+	if err != nil {
+		return err
+	}
+
+	keyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+
+	for _, path := range kubeconfigs {
+		kubeconfig, err := clientcmd.LoadFromFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+
+		updated := false
+		for _, authInfo := range kubeconfig.AuthInfos {
+			block, _ := pem.Decode(authInfo.ClientCertificateData)
+			if block == nil || !bytes.Equal(block.Bytes, oldCert.Raw) {
+				continue
+			}
+			authInfo.ClientCertificateData = certPEM
+			authInfo.ClientKeyData = keyPEM
+			updated = true
+		}
+		if !updated {
+			continue
+		}
+
+		err = clientcmd.WriteToFile(*kubeconfig, path)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		fmt.Printf("[kubeconfig] Updated the embedded client certificate in %s\n", path)
+	}
+
+	return nil
+}
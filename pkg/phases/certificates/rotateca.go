@@ -0,0 +1,211 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const caRotationStatePath = "/etc/kubernetes/pki/ca-rotation"
+
+// Steps of a cluster CA rotation, in order. The last completed step is
+// recorded in /etc/kubernetes/pki/ca-rotation so an interrupted rotation can
+// be resumed.
+const (
+	CARotationPrepared = "prepared"
+	CARotationTrusted  = "trusted"
+	CARotationReissued = "reissued"
+)
+
+// CARotationState returns the last completed CA rotation step, or "" when
+// no rotation is in progress.
+func CARotationState() (string, error) {
+	data, err := os.ReadFile(caRotationStatePath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read CA rotation state: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func SetCARotationState(state string) error {
+	err := os.WriteFile(caRotationStatePath, []byte(state+"\n"), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write CA rotation state: %w", err)
+	}
+	fmt.Printf("[rotate-ca] Step %q completed\n", state)
+	return nil
+}
+
+// PrepareCARotation keeps a copy of the current cluster CA as ca-old and
+// generates the new CA as ca-new. The new CA's common name carries its
+// generation time so that both CAs in the trusted bundle have distinct
+// subjects.
+func PrepareCARotation(cfg *config.ClusterConfiguration) error {
+	if IsExternalCA("ca") {
+		return fmt.Errorf("the cluster CA is external and must be rotated by its owner")
+	}
+
+	for _, ext := range []string{".crt", ".key"} {
+		oldPath := "/etc/kubernetes/pki/ca-old" + ext
+		if fileExists(oldPath) {
+			continue
+		}
+		data, err := os.ReadFile("/etc/kubernetes/pki/ca" + ext)
+		if err != nil {
+			return fmt.Errorf("failed to read current CA: %w", err)
+		}
+		err = os.WriteFile(oldPath, data, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to back up current CA: %w", err)
+		}
+	}
+
+	if fileExists("/etc/kubernetes/pki/ca-new.crt") {
+		fmt.Println("[rotate-ca] Using existing new CA")
+		return nil
+	}
+	return createCA(cfg, "ca-new", "kubernetes-ca-"+timeNow().UTC().Format("20060102150405"))
+}
+
+// TrustCABundle writes ca.crt as a bundle of the named CAs. The first one is
+// the CA whose key is installed as ca.key and signs new certificates.
+// Kubeconfigs embedding the cluster CA are updated with the same bundle,
+// which is returned.
+func TrustCABundle(signer string, others ...string) ([]byte, error) {
+	var bundle []byte
+	for _, name := range append([]string{signer}, others...) {
+		cert, err := LoadCertificate(name)
+		if err != nil {
+			return nil, err
+		}
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+
+	keyPEM, err := os.ReadFile(fmt.Sprintf("/etc/kubernetes/pki/%s.key", signer))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s key: %w", signer, err)
+	}
+	err = os.WriteFile("/etc/kubernetes/pki/ca.key", keyPEM, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write ca.key: %w", err)
+	}
+	err = os.WriteFile("/etc/kubernetes/pki/ca.crt", bundle, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write ca.crt: %w", err)
+	}
+	fmt.Printf("[rotate-ca] ca.crt now trusts %v, new certificates are signed by %s\n", append([]string{signer}, others...), signer)
+
+	return bundle, updateEmbeddedKubeconfigCA(bundle)
+}
+
+// ReissueCertificates re-signs with the current cluster CA every leaf
+// certificate, including the kubelet client certificate, still signed by
+// ca-old, keeping their keys. It returns the names of the reissued
+// certificates.
+func ReissueCertificates(validity time.Duration) ([]string, error) {
+	oldCA, err := LoadCertificate("ca-old")
+	if err != nil {
+		return nil, err
+	}
+
+	pkiCerts, err := loadPKICertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name, cert := range pkiCerts {
+		if cert.IsCA || cert.CheckSignatureFrom(oldCA) != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err = resignCertificate(name, pkiCerts[name], "ca", true, validity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	kubeletCert, caName, err := kubeletClientCertSigner(map[string]*x509.Certificate{"ca-old": oldCA})
+	if err != nil {
+		return nil, err
+	}
+	if caName != "" {
+		err = renewKubeletClientCert(kubeletCert, "ca", true, validity)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, KubeletClientCertName)
+	}
+	return names, nil
+}
+
+// CleanupCARotation removes the files kept during a CA rotation once the new
+// CA is the only trusted one.
+func CleanupCARotation() error {
+	for _, path := range []string{
+		"/etc/kubernetes/pki/ca-old.crt",
+		"/etc/kubernetes/pki/ca-old.key",
+		"/etc/kubernetes/pki/ca-new.crt",
+		"/etc/kubernetes/pki/ca-new.key",
+		caRotationStatePath,
+	} {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// updateEmbeddedKubeconfigCA replaces the embedded cluster CA of every
+// kubeconfig in /etc/kubernetes with bundle. Kubeconfigs referencing ca.crt
+// by path pick up the bundle on their own.
+func updateEmbeddedKubeconfigCA(bundle []byte) error {
+	kubeconfigs, err := filepath.Glob("/etc/kubernetes/*.conf")
+	if err != nil {
+		return err
+	}
+
+	for _, path := range kubeconfigs {
+		kubeconfig, err := clientcmd.LoadFromFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+
+		updated := false
+		for _, cluster := range kubeconfig.Clusters {
+			if len(cluster.CertificateAuthorityData) == 0 || bytes.Equal(cluster.CertificateAuthorityData, bundle) {
+				continue
+			}
+			cluster.CertificateAuthorityData = bundle
+			updated = true
+		}
+		if !updated {
+			continue
+		}
+
+		err = clientcmd.WriteToFile(*kubeconfig, path)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		fmt.Printf("[kubeconfig] Updated the embedded CA bundle in %s\n", path)
+	}
+
+	return nil
+}
@@ -1,8 +1,8 @@
 package certificates
 
 import (
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
@@ -10,27 +10,57 @@ import (
 	"math/big"
 	"net"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
 )
 
-func NewPrivateKey() (*rsa.PrivateKey, error) {
-	return rsa.GenerateKey(rand.Reader, 2048)
+// timeNow returns the issuing time of new certificates. It is a variable so the
+// clock can be replaced, e.g. to issue certificates at a fixed time.
+var timeNow = time.Now
+
+// certificateBackdate is subtracted from NotBefore so that certificates are
+// accepted right away by nodes whose clocks lag slightly behind.
+const certificateBackdate = 5 * time.Minute
+
+// leafValidity returns the NotBefore and NotAfter of a leaf certificate valid
+// for validity, capped so that it never outlives caCert.
+func leafValidity(validity time.Duration, caCert *x509.Certificate) (time.Time, time.Time) {
+	issued := timeNow()
+	notAfter := issued.Add(validity)
+	if notAfter.After(caCert.NotAfter) {
+		fmt.Printf("[certificate] WARNING: %s expires on %s, capping certificate validity to match\n",
+			caCert.Subject.CommonName, caCert.NotAfter.Format(time.RFC3339))
+		notAfter = caCert.NotAfter
+	}
+	return issued.Add(-certificateBackdate), notAfter
 }
 
-func NewCACert(caKey *rsa.PrivateKey) ([]byte, error) {
+func NewCACert(caKey crypto.Signer, commonName string, validity time.Duration) ([]byte, error) {
+	// A random serial keeps CAs with the same subject, such as the old and
+	// new CA during a rotation, apart.
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	issued := timeNow()
 	caCertTemplate := &x509.Certificate{
-		SerialNumber: big.NewInt(1),
+		SerialNumber: serialNumber,
 		Subject: pkix.Name{
-			CommonName: "kubernetes-ca",
+			CommonName: commonName,
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0),
+		NotBefore:             issued.Add(-certificateBackdate),
+		NotAfter:              issued.Add(validity),
 		IsCA:                  true,
 		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		BasicConstraintsValid: true,
 	}
 
-	certBytes, err := x509.CreateCertificate(rand.Reader, caCertTemplate, caCertTemplate, &caKey.PublicKey, caKey)
+	certBytes, err := x509.CreateCertificate(rand.Reader, caCertTemplate, caCertTemplate, caKey.Public(), caKey)
 
 	if err != nil {
 		return nil, err
@@ -47,8 +77,9 @@ type CertOpts struct {
 	IsServerCert bool
 }
 
-func NewServerCert(privKey *rsa.PrivateKey, certOpts CertOpts, caCert *x509.Certificate, caKey *rsa.PrivateKey) ([]byte, error) {
+func NewServerCert(privKey crypto.Signer, certOpts CertOpts, caCert *x509.Certificate, caKey crypto.Signer, validity time.Duration) ([]byte, error) {
 	serialNumber, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	notBefore, notAfter := leafValidity(validity, caCert)
 
 	certTemplate := &x509.Certificate{
 		SerialNumber: serialNumber,
@@ -56,20 +87,21 @@ func NewServerCert(privKey *rsa.PrivateKey, certOpts CertOpts, caCert *x509.Cert
 			CommonName:   certOpts.CommonName,
 			Organization: certOpts.Organization,
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(1, 0, 0),
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
 		IPAddresses:           certOpts.IPs,
 		DNSNames:              certOpts.DNSNames,
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		KeyUsage:              leafKeyUsage(privKey),
 		ExtKeyUsage:           certOpts.ExtKeyUsage,
 		BasicConstraintsValid: true,
 	}
 
-	return x509.CreateCertificate(rand.Reader, certTemplate, caCert, &privKey.PublicKey, caKey)
+	return x509.CreateCertificate(rand.Reader, certTemplate, caCert, privKey.Public(), caKey)
 }
 
-func NewClientCert(privKey *rsa.PrivateKey, certOpts CertOpts, caCert *x509.Certificate, caKey *rsa.PrivateKey) ([]byte, error) {
+func NewClientCert(privKey crypto.Signer, certOpts CertOpts, caCert *x509.Certificate, caKey crypto.Signer, validity time.Duration) ([]byte, error) {
 	serialNumber, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	notBefore, notAfter := leafValidity(validity, caCert)
 
 	certTemplate := &x509.Certificate{
 		SerialNumber: serialNumber,
@@ -77,9 +109,9 @@ func NewClientCert(privKey *rsa.PrivateKey, certOpts CertOpts, caCert *x509.Cert
 			CommonName:   certOpts.CommonName,
 			Organization: certOpts.Organization,
 		},
-		NotBefore: time.Now(),
-		NotAfter:  time.Now().AddDate(1, 0, 0),
-		KeyUsage:  x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		NotBefore: notBefore,
+		NotAfter:  notAfter,
+		KeyUsage:  leafKeyUsage(privKey),
 		ExtKeyUsage: []x509.ExtKeyUsage{
 			x509.ExtKeyUsageClientAuth,
 		},
@@ -89,18 +121,43 @@ func NewClientCert(privKey *rsa.PrivateKey, certOpts CertOpts, caCert *x509.Cert
 		rand.Reader,
 		certTemplate,
 		caCert,
-		&privKey.PublicKey,
+		privKey.Public(),
 		caKey,
 	)
 }
 
-func createCertificate(component string, certOpts CertOpts) error {
-	privKey, err := NewPrivateKey()
+// uniqueIPs returns ips without duplicates, keeping the first occurrence.
+func uniqueIPs(ips []net.IP) []net.IP {
+	var unique []net.IP
+	for _, ip := range ips {
+		if !slices.ContainsFunc(unique, ip.Equal) {
+			unique = append(unique, ip)
+		}
+	}
+	return unique
+}
+
+// uniqueDNSNames returns names without case-insensitive duplicates, keeping
+// the first occurrence.
+func uniqueDNSNames(names []string) []string {
+	var unique []string
+	for _, name := range names {
+		if !slices.ContainsFunc(unique, func(n string) bool { return strings.EqualFold(n, name) }) {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// createCertificate issues /etc/kubernetes/pki/<component>.crt signed by the
+// CA stored as /etc/kubernetes/pki/<caName>.crt.
+func createCertificate(cfg *config.ClusterConfiguration, component string, caName string, certOpts CertOpts) error {
+	privKey, err := NewPrivateKey(cfg.EncryptionAlgorithm)
 	if err != nil {
 		return fmt.Errorf("%s key generation failed: %s", component, err)
 	}
 
-	caCert, caKey, err := loadCA()
+	caCert, caKey, err := loadCA(caName)
 	if err != nil {
 		return fmt.Errorf("%s key generation failed: %s", component, err)
 	}
@@ -108,59 +165,68 @@ func createCertificate(component string, certOpts CertOpts) error {
 	var certBytes []byte
 
 	if certOpts.IsServerCert {
-		certBytes, err = NewServerCert(privKey, certOpts, caCert, caKey)
+		certBytes, err = NewServerCert(privKey, certOpts, caCert, caKey, cfg.CertificateValidityPeriod.Duration)
 	} else {
-		certBytes, err = NewClientCert(privKey, certOpts, caCert, caKey)
+		certBytes, err = NewClientCert(privKey, certOpts, caCert, caKey, cfg.CertificateValidityPeriod.Duration)
 	}
 	if err != nil {
 		return fmt.Errorf("%s cert generation failed: %s", component, err)
 	}
 
-	keyOut, err := os.Create(fmt.Sprintf("/etc/kubernetes/pki/%s.key", component))
+	err = writeCertificateAndKey(component, certBytes, privKey)
 	if err != nil {
-		return fmt.Errorf("%s key saving failed: %s", component, err)
+		return err
 	}
-	defer keyOut.Close()
-	pem.Encode(keyOut, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privKey),
-	})
-	os.Chmod(fmt.Sprintf("/etc/kubernetes/pki/%s.key", component), 0600)
 
-	crtOut, err := os.Create(fmt.Sprintf("/etc/kubernetes/pki/%s.crt", component))
+	fmt.Printf("[certificate] %s certificate successfully generated\n", component)
+
+	return nil
+}
+
+// writeCertificateAndKey writes /etc/kubernetes/pki/<name>.crt and, unless key
+// is nil, <name>.key.
+func writeCertificateAndKey(name string, certBytes []byte, key crypto.Signer) error {
+	err := os.MkdirAll(filepath.Dir(fmt.Sprintf("/etc/kubernetes/pki/%s.key", name)), 0755)
 	if err != nil {
-		return fmt.Errorf("%s cert saving failed: %s", component, err)
+		return fmt.Errorf("%s certificate directory creation failed: %s", name, err)
 	}
-	defer crtOut.Close()
-	pem.Encode(crtOut, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: certBytes,
-	})
-	os.Chmod(fmt.Sprintf("/etc/kubernetes/pki/%s.crt", component), 0600)
 
-	fmt.Printf("[certificate] %s certificate successfully generated\n", component)
+	if key != nil {
+		keyPEM, err := encodePrivateKeyPEM(key)
+		if err != nil {
+			return fmt.Errorf("%s key encoding failed: %s", name, err)
+		}
+		err = os.WriteFile(fmt.Sprintf("/etc/kubernetes/pki/%s.key", name), keyPEM, 0600)
+		if err != nil {
+			return fmt.Errorf("%s key saving failed: %s", name, err)
+		}
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+	err = os.WriteFile(fmt.Sprintf("/etc/kubernetes/pki/%s.crt", name), certPEM, 0600)
+	if err != nil {
+		return fmt.Errorf("%s cert saving failed: %s", name, err)
+	}
 
 	return nil
 }
 
-func createServiceAccountKeys() error {
-	saKey, err := NewPrivateKey()
+func createServiceAccountKeys(cfg *config.ClusterConfiguration) error {
+	saKey, err := NewPrivateKey(cfg.EncryptionAlgorithm)
 	if err != nil {
 		return fmt.Errorf("SA key generation failed: %s", err)
 	}
 
-	keyOut, err := os.Create("/etc/kubernetes/pki/sa.key")
+	keyPEM, err := encodePrivateKeyPEM(saKey)
+	if err != nil {
+		return fmt.Errorf("SA key encoding failed: %s", err)
+	}
+	err = os.WriteFile("/etc/kubernetes/pki/sa.key", keyPEM, 0600)
 	if err != nil {
 		return fmt.Errorf("SA key saving failed: %s", err)
 	}
-	defer keyOut.Close()
-	pem.Encode(keyOut, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(saKey),
-	})
-	os.Chmod("/etc/kubernetes/pki/sa.key", 0600)
 
-	pubKeyBytes, err := x509.MarshalPKIXPublicKey(&saKey.PublicKey)
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(saKey.Public())
 	if err != nil {
 		return fmt.Errorf("SA public key marshaling failed: %s", err)
 	}
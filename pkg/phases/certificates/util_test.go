@@ -0,0 +1,123 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+)
+
+var fixedTime = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+func setFixedClock(t *testing.T, now time.Time) {
+	t.Helper()
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+}
+
+func newTestCA(t *testing.T, validity time.Duration) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	caKey, err := NewPrivateKey(config.EncryptionAlgorithmECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certBytes, err := NewCACert(caKey, "test-ca", validity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return caCert, caKey
+}
+
+func TestNewCACertValidity(t *testing.T) {
+	setFixedClock(t, fixedTime)
+	validity := 10 * 365 * 24 * time.Hour
+
+	caCert, _ := newTestCA(t, validity)
+
+	if want := fixedTime.Add(-certificateBackdate); !caCert.NotBefore.Equal(want) {
+		t.Errorf("NotBefore = %s, want %s", caCert.NotBefore, want)
+	}
+	if want := fixedTime.Add(validity); !caCert.NotAfter.Equal(want) {
+		t.Errorf("NotAfter = %s, want %s", caCert.NotAfter, want)
+	}
+}
+
+func TestLeafValidity(t *testing.T) {
+	setFixedClock(t, fixedTime)
+	caValidity := 365 * 24 * time.Hour
+	caCert, _ := newTestCA(t, caValidity)
+
+	tests := []struct {
+		name         string
+		validity     time.Duration
+		wantNotAfter time.Time
+	}{
+		{
+			name:         "within CA validity",
+			validity:     30 * 24 * time.Hour,
+			wantNotAfter: fixedTime.Add(30 * 24 * time.Hour),
+		},
+		{
+			name:         "capped at CA expiry",
+			validity:     2 * caValidity,
+			wantNotAfter: fixedTime.Add(caValidity),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notBefore, notAfter := leafValidity(tt.validity, caCert)
+			if want := fixedTime.Add(-certificateBackdate); !notBefore.Equal(want) {
+				t.Errorf("NotBefore = %s, want %s", notBefore, want)
+			}
+			if !notAfter.Equal(tt.wantNotAfter) {
+				t.Errorf("NotAfter = %s, want %s", notAfter, tt.wantNotAfter)
+			}
+		})
+	}
+}
+
+func TestNewClientCertCappedAtCAExpiry(t *testing.T) {
+	setFixedClock(t, fixedTime)
+	caValidity := 24 * time.Hour
+	caCert, caKey := newTestCA(t, caValidity)
+
+	// Issue the leaf later than the CA so capping is visible.
+	issued := fixedTime.Add(time.Hour)
+	setFixedClock(t, issued)
+
+	privKey, err := NewPrivateKey(config.EncryptionAlgorithmECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certBytes, err := NewClientCert(privKey, CertOpts{CommonName: "test"}, caCert, caKey, 365*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := issued.Add(-certificateBackdate); !cert.NotBefore.Equal(want) {
+		t.Errorf("NotBefore = %s, want %s", cert.NotBefore, want)
+	}
+	if !cert.NotAfter.Equal(caCert.NotAfter) {
+		t.Errorf("NotAfter = %s, want CA NotAfter %s", cert.NotAfter, caCert.NotAfter)
+	}
+}
+
+func TestNewCACertRandomSerial(t *testing.T) {
+	first, _ := newTestCA(t, time.Hour)
+	second, _ := newTestCA(t, time.Hour)
+
+	if first.SerialNumber.Cmp(second.SerialNumber) == 0 {
+		t.Errorf("two CAs share serial %s", first.SerialNumber)
+	}
+}
@@ -0,0 +1,171 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// LoadCertificate reads /etc/kubernetes/pki/<name>.crt.
+func LoadCertificate(name string) (*x509.Certificate, error) {
+	certPEM, err := os.ReadFile(fmt.Sprintf("/etc/kubernetes/pki/%s.crt", name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s cert: %w", name, err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode %s cert", name)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s cert: %w", name, err)
+	}
+	return cert, nil
+}
+
+func loadPrivateKey(name string) (crypto.Signer, error) {
+	keyPEM, err := os.ReadFile(fmt.Sprintf("/etc/kubernetes/pki/%s.key", name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s key: %w", name, err)
+	}
+
+	key, err := parsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s key: %w", name, err)
+	}
+	return key, nil
+}
+
+// validateCertificate checks that the certificate and key on disk for cert
+// belong together, chain to its CA and carry the expected subject, SANs and
+// key usages.
+func validateCertificate(cert Certificate) error {
+	caCert, err := LoadCertificate(cert.CAName)
+	if err != nil {
+		return err
+	}
+
+	crt, err := LoadCertificate(cert.Name)
+	if err != nil {
+		return err
+	}
+
+	key, err := loadPrivateKey(cert.Name)
+	if err != nil {
+		return err
+	}
+
+	if !keyMatchesCertificate(key, crt) {
+		return fmt.Errorf("%s.key does not match %s.crt", cert.Name, cert.Name)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	_, err = crt.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify %s against %s: %w", cert.Name, cert.CAName, err)
+	}
+
+	if crt.Subject.CommonName != cert.Opts.CommonName {
+		return fmt.Errorf("expected common name %q, got %q", cert.Opts.CommonName, crt.Subject.CommonName)
+	}
+
+	for _, org := range cert.Opts.Organization {
+		if !slices.Contains(crt.Subject.Organization, org) {
+			return fmt.Errorf("missing organization %q", org)
+		}
+	}
+
+	for _, ip := range cert.Opts.IPs {
+		if !slices.ContainsFunc(crt.IPAddresses, ip.Equal) {
+			return fmt.Errorf("missing IP SAN %s", ip)
+		}
+	}
+
+	for _, dnsName := range cert.Opts.DNSNames {
+		if !slices.Contains(crt.DNSNames, dnsName) {
+			return fmt.Errorf("missing DNS SAN %s", dnsName)
+		}
+	}
+
+	for _, usage := range expectedExtKeyUsage(cert.Opts) {
+		if !slices.Contains(crt.ExtKeyUsage, usage) {
+			return fmt.Errorf("missing extended key usage %v", usage)
+		}
+	}
+
+	return nil
+}
+
+func expectedExtKeyUsage(certOpts CertOpts) []x509.ExtKeyUsage {
+	if certOpts.IsServerCert {
+		return certOpts.ExtKeyUsage
+	}
+	return []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+}
+
+func keyMatchesCertificate(key crypto.Signer, cert *x509.Certificate) bool {
+	pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(key.Public())
+}
+
+// validateCA checks that the CA certificate and key on disk belong together
+// and that the certificate is a CA that has not expired.
+func validateCA(ca CertificateAuthority) error {
+	caCert, caKey, err := loadCA(ca.Name)
+	if err != nil {
+		return err
+	}
+
+	if !caCert.IsCA {
+		return fmt.Errorf("%s.crt is not a CA certificate", ca.Name)
+	}
+	if !keyMatchesCertificate(caKey, caCert) {
+		return fmt.Errorf("%s.key does not match %s.crt", ca.Name, ca.Name)
+	}
+	if time.Now().After(caCert.NotAfter) {
+		return fmt.Errorf("%s.crt expired on %s", ca.Name, caCert.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// validateServiceAccountKeys checks that sa.pub is the public half of sa.key.
+func validateServiceAccountKeys() error {
+	saKey, err := loadPrivateKey("sa")
+	if err != nil {
+		return err
+	}
+
+	pubPEM, err := os.ReadFile("/etc/kubernetes/pki/sa.pub")
+	if err != nil {
+		return fmt.Errorf("failed to load sa public key: %w", err)
+	}
+	block, _ := pem.Decode(pubPEM)
+	if block == nil {
+		return fmt.Errorf("failed to decode sa public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse sa public key: %w", err)
+	}
+
+	saPub, ok := saKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !saPub.Equal(pub) {
+		return fmt.Errorf("sa.pub does not match sa.key")
+	}
+	return nil
+}
+
+// fileExists reports whether path exists.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
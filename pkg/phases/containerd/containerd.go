@@ -0,0 +1,171 @@
+package containerd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/images"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/initsystem"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/runtime"
+)
+
+// HostsDir holds one hosts.toml per mirrored registry, the registry
+// configuration format containerd 1.5 and later read.
+const HostsDir = "/etc/containerd/certs.d"
+
+var timeNow = time.Now
+
+// The version 2 format is read by containerd 1.x and migrated on load by
+// containerd 2.x.
+var configTemplate = template.Must(template.New("config.toml").Parse(`# Generated by k8sbootstrap
+version = 2
+
+[plugins]
+  [plugins."io.containerd.grpc.v1.cri"]
+    sandbox_image = "{{ .SandboxImage }}"
+    [plugins."io.containerd.grpc.v1.cri".containerd]
+      default_runtime_name = "runc"
+      [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc]
+        runtime_type = "io.containerd.runc.v2"
+        [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
+          SystemdCgroup = {{ .SystemdCgroup }}
+    [plugins."io.containerd.grpc.v1.cri".registry]
+      config_path = "{{ .HostsDir }}"
+`))
+
+var hostsTemplate = template.Must(template.New("hosts.toml").Parse(`# Generated by k8sbootstrap
+server = "{{ .Server }}"
+{{ range .Mirrors }}
+[host."{{ . }}"]
+  capabilities = ["pull", "resolve"]
+{{ end }}`))
+
+type file struct {
+	path    string
+	content []byte
+}
+
+// SetupContainerd renders the containerd configuration for cfg. Files that
+// differ from the ones on disk are shown as a diff, backed up and replaced,
+// then containerd is restarted to load them.
+func SetupContainerd(cfg *config.ClusterConfiguration) error {
+	files, err := renderFiles(cfg)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, f := range files {
+		updated, err := writeFile(f)
+		if err != nil {
+			return err
+		}
+		changed = changed || updated
+	}
+
+	if !changed {
+		fmt.Println("[containerd] Configuration is up to date")
+		return nil
+	}
+
+	initSystem := initsystem.SystemdInitSystem{}
+	err = initSystem.ServiceRestart("containerd")
+	if err != nil {
+		return fmt.Errorf("failed to restart containerd, restart it to load the new configuration: %w", err)
+	}
+
+	fmt.Println("[containerd] containerd restarted")
+	return nil
+}
+
+func renderFiles(cfg *config.ClusterConfiguration) ([]file, error) {
+	var buf bytes.Buffer
+	err := configTemplate.Execute(&buf, map[string]any{
+		"SandboxImage":  images.PauseImage(),
+		"SystemdCgroup": cfg.Kubelet.CgroupDriver != runtime.CgroupDriverCgroupfs,
+		"HostsDir":      HostsDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", runtime.ContainerdConfigPath, err)
+	}
+	files := []file{{path: runtime.ContainerdConfigPath, content: buf.Bytes()}}
+
+	registries := make([]string, 0, len(cfg.ContainerRuntime.RegistryMirrors))
+	for registry := range cfg.ContainerRuntime.RegistryMirrors {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	for _, registry := range registries {
+		server := "https://" + registry
+		if registry == "docker.io" {
+			server = "https://registry-1.docker.io"
+		}
+
+		var buf bytes.Buffer
+		err := hostsTemplate.Execute(&buf, map[string]any{
+			"Server":  server,
+			"Mirrors": cfg.ContainerRuntime.RegistryMirrors[registry],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to render mirrors of %s: %w", registry, err)
+		}
+		files = append(files, file{path: filepath.Join(HostsDir, registry, "hosts.toml"), content: buf.Bytes()})
+	}
+
+	return files, nil
+}
+
+// writeFile writes f unless the file on disk already has the same content,
+// and reports whether it was written.
+func writeFile(f file) (bool, error) {
+	current, err := os.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	exists := err == nil
+
+	if exists && bytes.Equal(current, f.content) {
+		return false, nil
+	}
+
+	if exists {
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(current)),
+			B:        difflib.SplitLines(string(f.content)),
+			FromFile: f.path,
+			ToFile:   f.path + " (k8sbootstrap)",
+			Context:  3,
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to diff %s: %w", f.path, err)
+		}
+		fmt.Printf("[containerd] Changes to %s:\n%s", f.path, diff)
+
+		backupPath := fmt.Sprintf("%s.%s.bak", f.path, timeNow().Format("20060102-150405"))
+		err = os.WriteFile(backupPath, current, 0644)
+		if err != nil {
+			return false, fmt.Errorf("failed to back up %s: %w", f.path, err)
+		}
+		fmt.Printf("[containerd] Backed up %s to %s\n", f.path, backupPath)
+	}
+
+	err = os.MkdirAll(filepath.Dir(f.path), 0755)
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", filepath.Dir(f.path), err)
+	}
+	err = os.WriteFile(f.path, f.content, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+
+	fmt.Printf("[containerd] %s written\n", f.path)
+	return true, nil
+}
@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/bootstraptoken"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/certificates"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ClientForToken discovers the cluster at server and returns a client that
+// authenticates with the bootstrap token. The CA is read from the public
+// cluster-info ConfigMap without verification and only trusted if its public
+// key matches caCertHash.
+func ClientForToken(server string, token string, caCertHash string) (*kubernetes.Clientset, error) {
+	if _, err := bootstraptoken.ParseToken(token); err != nil {
+		return nil, err
+	}
+
+	caData, err := retrieveValidatedCA(server, caCertHash)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(&rest.Config{
+		Host:        server,
+		BearerToken: token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: caData,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bootstrap client: %w", err)
+	}
+
+	fmt.Printf("[discovery] Cluster info validated against CA hash %s\n", caCertHash)
+	return client, nil
+}
+
+func retrieveValidatedCA(server string, caCertHash string) ([]byte, error) {
+	insecureClient, err := kubernetes.NewForConfig(&rest.Config{
+		Host: server,
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure: true,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	clusterInfo, err := insecureClient.CoreV1().ConfigMaps(metav1.NamespacePublic).Get(
+		context.TODO(), bootstraptoken.ClusterInfoConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the cluster-info ConfigMap: %w", err)
+	}
+
+	kubeconfig, err := clientcmd.Load([]byte(clusterInfo.Data[bootstraptoken.ClusterInfoKubeconfigKey]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cluster-info kubeconfig: %w", err)
+	}
+
+	for _, cluster := range kubeconfig.Clusters {
+		if caBundleMatches(cluster.CertificateAuthorityData, caCertHash) {
+			return cluster.CertificateAuthorityData, nil
+		}
+	}
+
+	return nil, fmt.Errorf("none of the CAs in cluster-info match the CA hash %s", caCertHash)
+}
+
+// caBundleMatches reports whether any certificate in the PEM bundle has a
+// public key matching caCertHash.
+func caBundleMatches(caData []byte, caCertHash string) bool {
+	for {
+		var block *pem.Block
+		block, caData = pem.Decode(caData)
+		if block == nil {
+			return false
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(certificates.PublicKeyHash(cert), caCertHash) {
+			return true
+		}
+	}
+}
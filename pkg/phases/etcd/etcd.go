@@ -0,0 +1,93 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/kubeconfig"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/manifests"
+	etcdutil "github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/etcd"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/network"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const promoteTimeout = 5 * time.Minute
+
+// JoinStackedEtcdMember adds this node to the existing stacked etcd cluster.
+// The member is added as a learner so it can't affect quorum while it
+// catches up, and is promoted to a voting member once it is in sync.
+func JoinStackedEtcdMember(cfg *config.ClusterConfiguration) error {
+	client, err := kubeconfig.ClientSetFromFile("/etc/kubernetes/admin.conf")
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := getEtcdEndpoints(client)
+	if err != nil {
+		return err
+	}
+
+	etcdClient, err := etcdutil.NewClient(
+		endpoints,
+		"/etc/kubernetes/pki/etcd/ca.crt",
+		"/etc/kubernetes/pki/apiserver-etcd-client.crt",
+		"/etc/kubernetes/pki/apiserver-etcd-client.key",
+	)
+	if err != nil {
+		return err
+	}
+
+	peerURL := network.FormatURL("https", cfg.AdvertiseAddress, 2380)
+	memberID, members, err := etcdClient.AddLearner(cfg.NodeName, peerURL)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[etcd] Added %s as learner member %x with peer URL %s\n", cfg.NodeName, memberID, peerURL)
+
+	err = os.MkdirAll("/etc/kubernetes/manifests", 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create manifests directory: %w", err)
+	}
+
+	err = manifests.SetupEtcdStaticPodManifest(cfg, members)
+	if err != nil {
+		return fmt.Errorf("failed to create etcd pod manifest: %w", err)
+	}
+
+	fmt.Println("[etcd] Waiting for the new member to catch up with the leader")
+	err = etcdClient.PromoteLearner(memberID, promoteTimeout)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[etcd] Member %s promoted to voting member\n", cfg.NodeName)
+	return nil
+}
+
+// getEtcdEndpoints reads the client URLs of the existing etcd members from
+// the annotations on their static pods.
+func getEtcdEndpoints(client kubernetes.Interface) ([]string, error) {
+	pods, err := client.CoreV1().Pods("kube-system").List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "component=etcd,tier=control-plane",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list etcd pods: %w", err)
+	}
+
+	var endpoints []string
+	for _, pod := range pods.Items {
+		if url, ok := pod.Annotations[etcdutil.AdvertiseClientURLsAnnotationKey]; ok {
+			endpoints = append(endpoints, strings.Split(url, ",")...)
+		}
+	}
+
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no etcd endpoints found in the %s annotation of etcd pods", etcdutil.AdvertiseClientURLsAnnotationKey)
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,75 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/certificates"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/apiclient"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const SuperAdminKubeconfigPath = "/etc/kubernetes/super-admin.conf"
+
+// SetupSuperAdminKubeconfig writes the break-glass super-admin.conf, readable
+// by root only.
+func SetupSuperAdminKubeconfig(cfg *config.ClusterConfiguration) error {
+	server, err := cfg.ControlPlaneURL()
+	if err != nil {
+		return err
+	}
+
+	err = CreateKubeconfig(
+		SuperAdminKubeconfigPath,
+		cfg.ClusterName,
+		certificates.SuperAdminCertificate.Opts.CommonName,
+		"/etc/kubernetes/pki/super-admin.crt",
+		"/etc/kubernetes/pki/super-admin.key",
+		server,
+	)
+	if err != nil {
+		return err
+	}
+
+	// WriteToFile keeps the mode of an existing file.
+	err = os.Chmod(SuperAdminKubeconfigPath, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to restrict permissions of %s: %w", SuperAdminKubeconfigPath, err)
+	}
+
+	fmt.Printf("[kubeconfig] WARNING: %s is in the system:masters group, which bypasses RBAC and cannot be revoked. "+
+		"Use /etc/kubernetes/admin.conf and keep %s for emergencies only\n", SuperAdminKubeconfigPath, SuperAdminKubeconfigPath)
+	return nil
+}
+
+// CreateClusterAdminsBinding grants cluster-admin to the group of the
+// admin.conf user. client must already be allowed to do so, e.g. through
+// super-admin.conf.
+func CreateClusterAdminsBinding(client kubernetes.Interface) error {
+	err := apiclient.CreateOrUpdateClusterRoleBinding(client, &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: certificates.ClusterAdminsGroup,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     "cluster-admin",
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:     rbacv1.GroupKind,
+				APIGroup: rbacv1.GroupName,
+				Name:     certificates.ClusterAdminsGroup,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[kubeconfig] Granted cluster-admin to the %s group of admin.conf\n", certificates.ClusterAdminsGroup)
+	return nil
+}
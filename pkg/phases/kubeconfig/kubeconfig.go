@@ -3,18 +3,26 @@ package kubeconfig
 import (
 	"fmt"
 
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/certificates"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
-func SetupKubeconfigs(advertiseAddress string) error {
-	err := CreateKubeconfig(
+func SetupKubeconfigs(cfg *config.ClusterConfiguration) error {
+	server, err := cfg.ControlPlaneURL()
+	if err != nil {
+		return err
+	}
+
+	err = CreateKubeconfig(
 		"/etc/kubernetes/admin.conf",
-		"kubernetes",
+		cfg.ClusterName,
 		"kubernetes-admin",
 		"/etc/kubernetes/pki/admin.crt",
 		"/etc/kubernetes/pki/admin.key",
-		advertiseAddress,
+		server,
 	)
 	if err != nil {
 		return err
@@ -22,11 +30,23 @@ func SetupKubeconfigs(advertiseAddress string) error {
 
 	err = CreateKubeconfig(
 		"/etc/kubernetes/scheduler.conf",
-		"kubernetes",
+		cfg.ClusterName,
 		"system:kube-scheduler",
-		"/etc/kubernetes/pki/scheduler.key",
 		"/etc/kubernetes/pki/scheduler.crt",
-		advertiseAddress,
+		"/etc/kubernetes/pki/scheduler.key",
+		server,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = CreateKubeconfig(
+		"/etc/kubernetes/kubelet.conf",
+		cfg.ClusterName,
+		fmt.Sprintf("system:node:%s", cfg.NodeName),
+		certificates.KubeletClientCertPath,
+		certificates.KubeletClientCertPath,
+		server,
 	)
 	if err != nil {
 		return err
@@ -34,11 +54,11 @@ func SetupKubeconfigs(advertiseAddress string) error {
 
 	err = CreateKubeconfig(
 		"/etc/kubernetes/controller-manager.conf",
-		"kubernetes",
+		cfg.ClusterName,
 		"system:kube-controller-manager",
 		"/etc/kubernetes/pki/controller-manager.crt",
 		"/etc/kubernetes/pki/controller-manager.key",
-		advertiseAddress,
+		server,
 	)
 	if err != nil {
 		return err
@@ -53,29 +73,33 @@ func CreateKubeconfig(
 	user string,
 	certPath string,
 	keyPath string,
-	advertiseAddress string,
+	server string,
 ) error {
 
+	// The user and context are qualified with the cluster name so that
+	// kubeconfigs of several clusters can be merged without collisions.
+	contextName := fmt.Sprintf("%s@%s", user, clusterName)
+
 	kubeconfig := clientcmdapi.Config{
 		Clusters: map[string]*clientcmdapi.Cluster{
 			clusterName: {
-				Server:               fmt.Sprintf("https://%s:6443", advertiseAddress),
+				Server:               server,
 				CertificateAuthority: "/etc/kubernetes/pki/ca.crt",
 			},
 		},
 		AuthInfos: map[string]*clientcmdapi.AuthInfo{
-			user: {
+			contextName: {
 				ClientKey:         keyPath,
 				ClientCertificate: certPath,
 			},
 		},
 		Contexts: map[string]*clientcmdapi.Context{
-			clusterName: {
+			contextName: {
 				Cluster:  clusterName,
-				AuthInfo: user,
+				AuthInfo: contextName,
 			},
 		},
-		CurrentContext: fmt.Sprintf("%s@%s", user, clusterName),
+		CurrentContext: contextName,
 	}
 
 	err := clientcmd.WriteToFile(kubeconfig, kubeconfigPath)
@@ -86,3 +110,16 @@ func CreateKubeconfig(
 	fmt.Printf("[kubeconfig] %s kubeconfig successfully generated\n", user)
 	return nil
 }
+
+func ClientSetFromFile(kubeconfigPath string) (*kubernetes.Clientset, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig %s: %w", kubeconfigPath, err)
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client from %s: %w", kubeconfigPath, err)
+	}
+	return client, nil
+}
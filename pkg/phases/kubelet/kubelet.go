@@ -0,0 +1,183 @@
+package kubelet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/cgroups"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/initsystem"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/network"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/runtime"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeletconfigv1beta1 "k8s.io/kubelet/config/v1beta1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ConfigPath   = "/var/lib/kubelet/config.yaml"
+	FlagsEnvPath = "/var/lib/kubelet/k8sbootstrap-flags.env"
+	DropInPath   = "/etc/systemd/system/kubelet.service.d/10-k8sbootstrap.conf"
+)
+
+// dropIn points the kubelet at its kubeconfig, its configuration file and
+// the node specific flags. KUBELET_EXTRA_ARGS in /etc/default/kubelet is kept
+// for user overrides, as in the kubeadm packages.
+var dropIn = `# Generated by k8sbootstrap
+[Service]
+Environment="KUBELET_KUBECONFIG_ARGS=--kubeconfig=/etc/kubernetes/kubelet.conf"
+Environment="KUBELET_CONFIG_ARGS=--config=` + ConfigPath + `"
+EnvironmentFile=-` + FlagsEnvPath + `
+EnvironmentFile=-/etc/default/kubelet
+ExecStart=
+ExecStart=/usr/bin/kubelet $KUBELET_KUBECONFIG_ARGS $KUBELET_CONFIG_ARGS $KUBELET_K8SBOOTSTRAP_ARGS $KUBELET_EXTRA_ARGS
+`
+
+// SetupKubelet writes the kubelet configuration, flags and systemd drop-in,
+// then restarts the kubelet so it starts the static pods.
+func SetupKubelet(cfg *config.ClusterConfiguration) error {
+	err := writeKubeletConfiguration(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = writeFlagsEnvFile(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = writeFile(DropInPath, []byte(dropIn), 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[kubelet] systemd drop-in written to %s\n", DropInPath)
+
+	return RestartKubelet()
+}
+
+// RestartKubelet restarts the kubelet service, e.g. to load a new client
+// certificate. The containers it runs are left running.
+func RestartKubelet() error {
+	initSystem := initsystem.SystemdInitSystem{}
+	err := initSystem.ServiceRestart("kubelet")
+	if err != nil {
+		return fmt.Errorf("failed to restart kubelet: %w", err)
+	}
+
+	fmt.Println("[kubelet] kubelet restarted")
+	return nil
+}
+
+// ResolveCgroupDriver returns the cgroup driver for the kubelet, which must
+// be the one the container runtime uses. A driver set in the configuration
+// that differs from the runtime's is an error.
+func ResolveCgroupDriver(cfg *config.ClusterConfiguration) (string, error) {
+	runtimeDriver, err := runtime.CgroupDriver()
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.Kubelet.CgroupDriver != "" && cfg.Kubelet.CgroupDriver != runtimeDriver {
+		return "", fmt.Errorf("kubelet.cgroupDriver is %q but containerd uses the %q cgroup driver, "+
+			"set SystemdCgroup = %t for the runc runtime in %s or change kubelet.cgroupDriver",
+			cfg.Kubelet.CgroupDriver, runtimeDriver, cfg.Kubelet.CgroupDriver == runtime.CgroupDriverSystemd, runtime.ContainerdConfigPath)
+	}
+
+	if runtimeDriver == runtime.CgroupDriverSystemd && !cgroups.IsSystemdRunning() {
+		return "", fmt.Errorf("containerd uses the systemd cgroup driver but systemd is not running")
+	}
+	return runtimeDriver, nil
+}
+
+func writeKubeletConfiguration(cfg *config.ClusterConfiguration) error {
+	clusterDNS, err := network.ClusterDNSIP(cfg.Networking.ServiceSubnet)
+	if err != nil {
+		return err
+	}
+
+	cgroupDriver, err := ResolveCgroupDriver(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[kubelet] Using the %s cgroup driver, matching containerd\n", cgroupDriver)
+
+	kubeletConfig := &kubeletconfigv1beta1.KubeletConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: kubeletconfigv1beta1.SchemeGroupVersion.String(),
+			Kind:       "KubeletConfiguration",
+		},
+		StaticPodPath:            "/etc/kubernetes/manifests",
+		ContainerRuntimeEndpoint: runtime.ContainerdEndpoint,
+		CgroupDriver:             cgroupDriver,
+		ClusterDNS:               []string{clusterDNS.String()},
+		ClusterDomain:            cfg.Networking.DNSDomain,
+		Authentication: kubeletconfigv1beta1.KubeletAuthentication{
+			X509: kubeletconfigv1beta1.KubeletX509Authentication{
+				ClientCAFile: "/etc/kubernetes/pki/ca.crt",
+			},
+			Webhook: kubeletconfigv1beta1.KubeletWebhookAuthentication{
+				Enabled: ptr.To(true),
+			},
+			Anonymous: kubeletconfigv1beta1.KubeletAnonymousAuthentication{
+				Enabled: ptr.To(false),
+			},
+		},
+		Authorization: kubeletconfigv1beta1.KubeletAuthorization{
+			Mode: kubeletconfigv1beta1.KubeletAuthorizationModeWebhook,
+		},
+		RotateCertificates: true,
+	}
+
+	// The kubelet refuses to start on cgroup v1 unless told otherwise.
+	if !cgroups.IsCgroup2UnifiedMode() {
+		kubeletConfig.FailCgroupV1 = ptr.To(false)
+		fmt.Println("[kubelet] cgroup v1 detected, setting failCgroupV1 to false")
+	}
+
+	data, err := yaml.Marshal(kubeletConfig)
+	if err != nil {
+		return fmt.Errorf("failed to serialize kubelet configuration: %w", err)
+	}
+
+	err = writeFile(ConfigPath, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[kubelet] KubeletConfiguration written to %s\n", ConfigPath)
+	return nil
+}
+
+// writeFlagsEnvFile writes the flags that are specific to this node and
+// cannot be set in the KubeletConfiguration.
+func writeFlagsEnvFile(cfg *config.ClusterConfiguration) error {
+	flags := []string{
+		fmt.Sprintf("--hostname-override=%s", cfg.NodeName),
+		fmt.Sprintf("--node-ip=%s", cfg.AdvertiseAddress),
+	}
+
+	data := fmt.Sprintf("KUBELET_K8SBOOTSTRAP_ARGS=\"%s\"\n", strings.Join(flags, " "))
+	err := writeFile(FlagsEnvPath, []byte(data), 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[kubelet] kubelet flags written to %s\n", FlagsEnvPath)
+	return nil
+}
+
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	err = os.WriteFile(path, data, perm)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -2,8 +2,15 @@ package manifests
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"strings"
 
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/certificates"
+	etcdutil "github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/etcd"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/images"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/network"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -13,30 +20,42 @@ import (
 
 var hostPathDirectoryOrCreate = v1.HostPathDirectoryOrCreate
 var hostPathFileOrCreate = v1.HostPathFileOrCreate
-var hostname, _ = os.Hostname()
 
-func SetupStaticPodManifests(advertiseAddress string) error {
+func SetupStaticPodManifests(cfg *config.ClusterConfiguration) error {
 	err := os.MkdirAll("/etc/kubernetes/manifests", 0755)
 	if err != nil {
 		return fmt.Errorf("failed to create manifests directory: %w", err)
 	}
 
-	err = SetupEtcdStaticPodManifest(advertiseAddress)
+	if cfg.Etcd.External == nil {
+		err = SetupEtcdStaticPodManifest(cfg, nil)
+		if err != nil {
+			return fmt.Errorf("failed to etcd pod manifest: %w", err)
+		}
+	}
+
+	return SetupControlPlaneStaticPodManifests(cfg)
+}
+
+// SetupControlPlaneStaticPodManifests writes the apiserver, controller
+// manager and scheduler manifests, leaving etcd to the caller.
+func SetupControlPlaneStaticPodManifests(cfg *config.ClusterConfiguration) error {
+	err := os.MkdirAll("/etc/kubernetes/manifests", 0755)
 	if err != nil {
-		return fmt.Errorf("failed to etcd pod manifest: %w", err)
+		return fmt.Errorf("failed to create manifests directory: %w", err)
 	}
 
-	err = SetupApiserverStaticPodManifest(advertiseAddress)
+	err = SetupApiserverStaticPodManifest(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create apiserver pod manifest: %w", err)
 	}
 
-	err = SetupControllerManagerStaticPodManifest()
+	err = SetupControllerManagerStaticPodManifest(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create controller manager pod manifest: %w", err)
 	}
 
-	err = SetupSchedulerStaticPodManifest()
+	err = SetupSchedulerStaticPodManifest(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create scheduler pod manifest: %w", err)
 	}
@@ -44,7 +63,97 @@ func SetupStaticPodManifests(advertiseAddress string) error {
 	return nil
 }
 
-func SetupApiserverStaticPodManifest(advertiseAddress string) error {
+func SetupApiserverStaticPodManifest(cfg *config.ClusterConfiguration) error {
+	advertiseAddress := cfg.AdvertiseAddress
+	loopbackAddress := network.LoopbackAddress(advertiseAddress)
+
+	bindAddress := "0.0.0.0"
+	if network.IsIPv6(net.ParseIP(advertiseAddress)) {
+		bindAddress = "::"
+	}
+
+	etcdServers := network.FormatURL("https", loopbackAddress, 2379)
+	etcdCAFile := "/etc/kubernetes/pki/etcd/ca.crt"
+	etcdCertFile := "/etc/kubernetes/pki/apiserver-etcd-client.crt"
+	etcdKeyFile := "/etc/kubernetes/pki/apiserver-etcd-client.key"
+
+	volumes := []corev1.Volume{
+		{
+			Name: "k8s-certs",
+			VolumeSource: v1.VolumeSource{
+				HostPath: &v1.HostPathVolumeSource{
+					Path: "/etc/kubernetes/pki/",
+					Type: &hostPathDirectoryOrCreate,
+				},
+			},
+		},
+	}
+	volumeMounts := []v1.VolumeMount{
+		{
+			Name:      "k8s-certs",
+			MountPath: "/etc/kubernetes/pki/",
+			ReadOnly:  true,
+		},
+	}
+
+	if external := cfg.Etcd.External; external != nil {
+		etcdServers = strings.Join(external.Endpoints, ",")
+		etcdCAFile = external.CAFile
+		etcdCertFile = external.CertFile
+		etcdKeyFile = external.KeyFile
+
+		externalVolumes, externalVolumeMounts := externalEtcdVolumes(external)
+		volumes = append(volumes, externalVolumes...)
+		volumeMounts = append(volumeMounts, externalVolumeMounts...)
+	}
+
+	command := []string{
+		"kube-apiserver",
+		fmt.Sprintf("--advertise-address=%s", advertiseAddress),
+		"--allow-privileged=true",
+		fmt.Sprintf("--bind-address=%s", bindAddress),
+		"--authorization-mode=Node,RBAC",
+		"--client-ca-file=/etc/kubernetes/pki/ca.crt",
+		"--enable-admission-plugins=NodeRestriction",
+		"--enable-bootstrap-token-auth=true",
+	}
+	// An external etcd reached over http has no client certificates.
+	for _, flag := range [][2]string{
+		{"--etcd-cafile", etcdCAFile},
+		{"--etcd-certfile", etcdCertFile},
+		{"--etcd-keyfile", etcdKeyFile},
+	} {
+		if flag[1] != "" {
+			command = append(command, fmt.Sprintf("%s=%s", flag[0], flag[1]))
+		}
+	}
+	command = append(command,
+		fmt.Sprintf("--etcd-servers=%s", etcdServers),
+		"--kubelet-client-certificate=/etc/kubernetes/pki/apiserver-kubelet-client.crt",
+		"--kubelet-client-key=/etc/kubernetes/pki/apiserver-kubelet-client.key",
+		"--kubelet-preferred-address-types=InternalIP,ExternalIP,Hostname",
+		"--proxy-client-cert-file=/etc/kubernetes/pki/front-proxy-client.crt",
+		"--proxy-client-key-file=/etc/kubernetes/pki/front-proxy-client.key",
+		"--requestheader-allowed-names=front-proxy-client",
+		"--requestheader-client-ca-file=/etc/kubernetes/pki/front-proxy-ca.crt",
+		"--requestheader-extra-headers-prefix=X-Remote-Extra-",
+		"--requestheader-group-headers=X-Remote-Group",
+		"--requestheader-username-headers=X-Remote-User",
+		"--runtime-config=",
+		"--secure-port=6443",
+		fmt.Sprintf("--service-account-issuer=https://kubernetes.default.svc.%s", cfg.Networking.DNSDomain),
+		"--service-account-key-file=/etc/kubernetes/pki/sa.pub",
+		"--service-account-signing-key-file=/etc/kubernetes/pki/sa.key",
+		fmt.Sprintf("--service-cluster-ip-range=%s", cfg.Networking.ServiceSubnet),
+		"--tls-cert-file=/etc/kubernetes/pki/apiserver.crt",
+		"--tls-private-key-file=/etc/kubernetes/pki/apiserver.key",
+	)
+
+	resources, err := resourceRequirements(cfg.APIServer.Resources)
+	if err != nil {
+		return fmt.Errorf("invalid apiServer resources: %w", err)
+	}
+
 	apiserverPod := &corev1.Pod{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -64,64 +173,21 @@ func SetupApiserverStaticPodManifest(advertiseAddress string) error {
 			RestartPolicy:     corev1.RestartPolicyAlways,
 			Containers: []corev1.Container{
 				{
-					Name:  "kube-apiserver",
-					Image: "registry.k8s.io/kube-apiserver:v1.35.0",
-					Command: []string{
-						"kube-apiserver",
-						fmt.Sprintf("--advertise-address=%s", advertiseAddress),
-						"--allow-privileged=true",
-						"--bind-address=0.0.0.0",
-						"--authorization-mode=Node,RBAC",
-						"--client-ca-file=/etc/kubernetes/pki/ca.crt",
-						"--enable-admission-plugins=NodeRestriction",
-						"--enable-bootstrap-token-auth=true",
-						"--etcd-cafile=/etc/kubernetes/pki/ca.crt",
-						"--etcd-certfile=/etc/kubernetes/pki/apiserver-etcd-client.crt",
-						"--etcd-keyfile=/etc/kubernetes/pki/apiserver-etcd-client.key",
-						"--etcd-servers=https://127.0.0.1:2379",
-						"--kubelet-client-certificate=/etc/kubernetes/pki/apiserver-kubelet-client.crt",
-						"--kubelet-client-key=/etc/kubernetes/pki/apiserver-kubelet-client.key",
-						"--kubelet-preferred-address-types=InternalIP,ExternalIP,Hostname",
-						// "--proxy-client-cert-file=/etc/kubernetes/pki/front-proxy-client.crt",
-						// "--proxy-client-key-file=/etc/kubernetes/pki/front-proxy-client.key",
-						// "--requestheader-allowed-names=front-proxy-client",
-						// "--requestheader-client-ca-file=/etc/kubernetes/pki/front-proxy-ca.crt",
-						"--requestheader-extra-headers-prefix=X-Remote-Extra-",
-						"--requestheader-group-headers=X-Remote-Group",
-						"--requestheader-username-headers=X-Remote-User",
-						"--runtime-config=",
-						"--secure-port=6443",
-						"--service-account-issuer=https://kubernetes.default.svc.cluster.local",
-						"--service-account-key-file=/etc/kubernetes/pki/sa.pub",
-						"--service-account-signing-key-file=/etc/kubernetes/pki/sa.key",
-						"--service-cluster-ip-range=10.96.0.0/16",
-						"--tls-cert-file=/etc/kubernetes/pki/apiserver.crt",
-						"--tls-private-key-file=/etc/kubernetes/pki/apiserver.key",
-					},
-					VolumeMounts: []v1.VolumeMount{
-						{
-							Name:      "k8s-certs",
-							MountPath: "/etc/kubernetes/pki/",
-							ReadOnly:  true,
-						},
-					},
-				},
-			},
-			Volumes: []corev1.Volume{
-				{
-					Name: "k8s-certs",
-					VolumeSource: v1.VolumeSource{
-						HostPath: &v1.HostPathVolumeSource{
-							Path: "/etc/kubernetes/pki/",
-							Type: &hostPathDirectoryOrCreate,
-						},
-					},
+					Name:           "kube-apiserver",
+					Image:          images.KubernetesImage("kube-apiserver"),
+					Command:        command,
+					LivenessProbe:  livenessProbe(advertiseAddress, "/livez", 6443, corev1.URISchemeHTTPS),
+					ReadinessProbe: readinessProbe(advertiseAddress, "/readyz", 6443, corev1.URISchemeHTTPS),
+					StartupProbe:   startupProbe(advertiseAddress, "/livez", 6443, corev1.URISchemeHTTPS),
+					Resources:      resources,
+					VolumeMounts:   volumeMounts,
 				},
 			},
+			Volumes: volumes,
 		},
 	}
 
-	err := writePodManifest(apiserverPod, "/etc/kubernetes/manifests/kube-apiserver.yaml")
+	err = writePodManifest(apiserverPod, "/etc/kubernetes/manifests/kube-apiserver.yaml")
 	if err != nil {
 		return fmt.Errorf("failed to write apiserver manifest: %w", err)
 	}
@@ -130,7 +196,47 @@ func SetupApiserverStaticPodManifest(advertiseAddress string) error {
 	return nil
 }
 
-func SetupControllerManagerStaticPodManifest() error {
+func SetupControllerManagerStaticPodManifest(cfg *config.ClusterConfiguration) error {
+	command := []string{
+		"kube-controller-manager",
+		"--allocate-node-cidrs=true",
+		"--authentication-kubeconfig=/etc/kubernetes/controller-manager.conf",
+		"--authorization-kubeconfig=/etc/kubernetes/controller-manager.conf",
+		"--bind-address=127.0.0.1",
+		"--client-ca-file=/etc/kubernetes/pki/ca.crt",
+		fmt.Sprintf("--cluster-cidr=%s", cfg.Networking.PodSubnet),
+		fmt.Sprintf("--cluster-name=%s", cfg.ClusterName),
+		"--controllers=*,bootstrapsigner,tokencleaner",
+		"--enable-hostpath-provisioner=true",
+		"--kubeconfig=/etc/kubernetes/controller-manager.conf",
+		"--leader-elect=true",
+		"--requestheader-client-ca-file=/etc/kubernetes/pki/front-proxy-ca.crt",
+		"--root-ca-file=/etc/kubernetes/pki/ca.crt",
+		"--service-account-private-key-file=/etc/kubernetes/pki/sa.key",
+		fmt.Sprintf("--service-cluster-ip-range=%s", cfg.Networking.ServiceSubnet),
+		"--use-service-account-credentials=true",
+	}
+
+	nodeCIDRMaskFlags, err := nodeCIDRMaskSizeFlags(cfg.Networking.PodSubnet)
+	if err != nil {
+		return err
+	}
+	command = append(command, nodeCIDRMaskFlags...)
+
+	// Without the CA key the controller-manager cannot sign CSRs, so kubelet
+	// client certificates must be issued by the external CA.
+	if !certificates.IsExternalCA("ca") {
+		command = append(command,
+			"--cluster-signing-cert-file=/etc/kubernetes/pki/ca.crt",
+			"--cluster-signing-key-file=/etc/kubernetes/pki/ca.key",
+		)
+	}
+
+	resources, err := resourceRequirements(cfg.ControllerManager.Resources)
+	if err != nil {
+		return fmt.Errorf("invalid controllerManager resources: %w", err)
+	}
+
 	controllerManagerPod := &corev1.Pod{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -150,29 +256,12 @@ func SetupControllerManagerStaticPodManifest() error {
 			RestartPolicy:     corev1.RestartPolicyAlways,
 			Containers: []corev1.Container{
 				{
-					Name:  "kube-conrtoller-manager",
-					Image: "registry.k8s.io/kube-controller-manager:v1.35.0",
-					Command: []string{
-						"kube-controller-manager",
-						"--allocate-node-cidrs=true",
-						"--authentication-kubeconfig=/etc/kubernetes/controller-manager.conf",
-						"--authorization-kubeconfig=/etc/kubernetes/controller-manager.conf",
-						"--bind-address=127.0.0.1",
-						"--client-ca-file=/etc/kubernetes/pki/ca.crt",
-						"--cluster-cidr=10.244.0.0/16",
-						"--cluster-name=kubernetes",
-						"--cluster-signing-cert-file=/etc/kubernetes/pki/ca.crt",
-						"--cluster-signing-key-file=/etc/kubernetes/pki/ca.key",
-						"--controllers=*,bootstrapsigner,tokencleaner",
-						"--enable-hostpath-provisioner=true",
-						"--kubeconfig=/etc/kubernetes/controller-manager.conf",
-						"--leader-elect=true",
-						// "--requestheader-client-ca-file=/etc/kubernetes/pki/front-proxy-ca.crt",
-						"--root-ca-file=/etc/kubernetes/pki/ca.crt",
-						"--service-account-private-key-file=/etc/kubernetes/pki/sa.key",
-						"--service-cluster-ip-range=10.96.0.0/16",
-						"--use-service-account-credentials=true",
-					},
+					Name:          "kube-conrtoller-manager",
+					Image:         images.KubernetesImage("kube-controller-manager"),
+					Command:       command,
+					LivenessProbe: livenessProbe("127.0.0.1", "/healthz", 10257, corev1.URISchemeHTTPS),
+					StartupProbe:  startupProbe("127.0.0.1", "/healthz", 10257, corev1.URISchemeHTTPS),
+					Resources:     resources,
 					VolumeMounts: []v1.VolumeMount{
 						{
 							Name:      "kubeconfig",
@@ -210,7 +299,7 @@ func SetupControllerManagerStaticPodManifest() error {
 		},
 	}
 
-	err := writePodManifest(controllerManagerPod, "/etc/kubernetes/manifests/kube-controller-manager.yaml")
+	err = writePodManifest(controllerManagerPod, "/etc/kubernetes/manifests/kube-controller-manager.yaml")
 	if err != nil {
 		return fmt.Errorf("failed to write controller manager manifest: %w", err)
 	}
@@ -219,7 +308,12 @@ func SetupControllerManagerStaticPodManifest() error {
 	return nil
 }
 
-func SetupSchedulerStaticPodManifest() error {
+func SetupSchedulerStaticPodManifest(cfg *config.ClusterConfiguration) error {
+	resources, err := resourceRequirements(cfg.Scheduler.Resources)
+	if err != nil {
+		return fmt.Errorf("invalid scheduler resources: %w", err)
+	}
+
 	schedulerPod := &corev1.Pod{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -240,7 +334,7 @@ func SetupSchedulerStaticPodManifest() error {
 			Containers: []corev1.Container{
 				{
 					Name:  "kube-apiserver",
-					Image: "registry.k8s.io/kube-scheduler:v1.35.0",
+					Image: images.KubernetesImage("kube-scheduler"),
 					Command: []string{
 						"kube-scheduler",
 						"--authentication-kubeconfig=/etc/kubernetes/scheduler.conf",
@@ -249,6 +343,9 @@ func SetupSchedulerStaticPodManifest() error {
 						"--kubeconfig=/etc/kubernetes/scheduler.conf",
 						"--leader-elect=true",
 					},
+					LivenessProbe: livenessProbe("127.0.0.1", "/healthz", 10259, corev1.URISchemeHTTPS),
+					StartupProbe:  startupProbe("127.0.0.1", "/healthz", 10259, corev1.URISchemeHTTPS),
+					Resources:     resources,
 					VolumeMounts: []v1.VolumeMount{
 						{
 							Name:      "kubeconfig",
@@ -272,7 +369,7 @@ func SetupSchedulerStaticPodManifest() error {
 		},
 	}
 
-	err := writePodManifest(schedulerPod, "/etc/kubernetes/manifests/kube-scheduler.yaml")
+	err = writePodManifest(schedulerPod, "/etc/kubernetes/manifests/kube-scheduler.yaml")
 	if err != nil {
 		return fmt.Errorf("failed to write scheduler manifest: %w", err)
 	}
@@ -281,7 +378,31 @@ func SetupSchedulerStaticPodManifest() error {
 	return nil
 }
 
-func SetupEtcdStaticPodManifest(advertiseAddress string) error {
+// SetupEtcdStaticPodManifest writes the manifest for a stacked etcd member.
+// With no initialCluster a new single-member cluster is bootstrapped,
+// otherwise the member joins the existing cluster made of initialCluster.
+func SetupEtcdStaticPodManifest(cfg *config.ClusterConfiguration, initialCluster []etcdutil.Member) error {
+	advertiseAddress := cfg.AdvertiseAddress
+	loopbackAddress := network.LoopbackAddress(advertiseAddress)
+	clientURL := network.FormatURL("https", advertiseAddress, 2379)
+	peerURL := network.FormatURL("https", advertiseAddress, 2380)
+
+	initialClusterState := "existing"
+	if len(initialCluster) == 0 {
+		initialClusterState = "new"
+		initialCluster = []etcdutil.Member{{Name: cfg.NodeName, PeerURL: peerURL}}
+	}
+
+	var initialClusterMembers []string
+	for _, member := range initialCluster {
+		initialClusterMembers = append(initialClusterMembers, fmt.Sprintf("%s=%s", member.Name, member.PeerURL))
+	}
+
+	resources, err := resourceRequirements(cfg.Etcd.Resources)
+	if err != nil {
+		return fmt.Errorf("invalid etcd resources: %w", err)
+	}
+
 	etcdPod := &corev1.Pod{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -294,6 +415,9 @@ func SetupEtcdStaticPodManifest(advertiseAddress string) error {
 				"component": "etcd",
 				"tier":      "control-plane",
 			},
+			Annotations: map[string]string{
+				etcdutil.AdvertiseClientURLsAnnotationKey: clientURL,
+			},
 		},
 		Spec: corev1.PodSpec{
 			HostNetwork:       true,
@@ -302,33 +426,40 @@ func SetupEtcdStaticPodManifest(advertiseAddress string) error {
 			Containers: []corev1.Container{
 				{
 					Name:  "etcd",
-					Image: "registry.k8s.io/etcd:3.6.6-0",
+					Image: images.EtcdImage(),
 					Command: []string{
 						"etcd",
-						fmt.Sprintf("--advertise-client-urls=https://%s:2379", advertiseAddress),
+						fmt.Sprintf("--advertise-client-urls=%s", clientURL),
 						"--cert-file=/etc/kubernetes/pki/etcd/server.crt",
 						"--client-cert-auth=true",
 						"--data-dir=/var/lib/etcd",
 						"--experimental-initial-corrupt-check=true",
 						"--experimental-watch-progress-notify-interval=5s",
-						// fmt.Sprintf("--initial-advertise-peer-urls=https://%s:2380", advertiseAddress),
-						// fmt.Sprintf("--initial-cluster=%s=https://%s:2380", hostname, advertiseAddress),
+						fmt.Sprintf("--initial-advertise-peer-urls=%s", peerURL),
+						fmt.Sprintf("--initial-cluster=%s", strings.Join(initialClusterMembers, ",")),
+						fmt.Sprintf("--initial-cluster-state=%s", initialClusterState),
 						"--key-file=/etc/kubernetes/pki/etcd/server.key",
-						fmt.Sprintf("--listen-client-urls=https://127.0.0.1:2379,https://%s:2379", advertiseAddress),
-						"--listen-metrics-urls=http://127.0.0.1:2381",
-						// fmt.Sprintf("--listen-peer-urls=https://%s:2380", advertiseAddress),
-						fmt.Sprintf("--name=%s", hostname),
-						// "--peer-cert-file=/etc/kubernetes/pki/etcd/peer.crt",
-						// "--peer-client-cert-auth=false",
-						// "--peer-key-file=/etc/kubernetes/pki/etcd/peer.key",
-						// "--peer-trusted-ca-file=/etc/kubernetes/pki/ca.crt",
+						fmt.Sprintf("--listen-client-urls=%s,%s",
+							network.FormatURL("https", loopbackAddress, 2379),
+							clientURL,
+						),
+						fmt.Sprintf("--listen-metrics-urls=%s", network.FormatURL("http", loopbackAddress, 2381)),
+						fmt.Sprintf("--listen-peer-urls=%s", peerURL),
+						fmt.Sprintf("--name=%s", cfg.NodeName),
+						"--peer-cert-file=/etc/kubernetes/pki/etcd/peer.crt",
+						"--peer-client-cert-auth=true",
+						"--peer-key-file=/etc/kubernetes/pki/etcd/peer.key",
+						"--peer-trusted-ca-file=/etc/kubernetes/pki/etcd/ca.crt",
 						"--snapshot-count=10000",
-						"--trusted-ca-file=/etc/kubernetes/pki/ca.crt",
+						"--trusted-ca-file=/etc/kubernetes/pki/etcd/ca.crt",
 					},
+					LivenessProbe: livenessProbe(loopbackAddress, "/health?exclude=NOSPACE&serializable=true", 2381, corev1.URISchemeHTTP),
+					StartupProbe:  startupProbe(loopbackAddress, "/health?serializable=false", 2381, corev1.URISchemeHTTP),
+					Resources:     resources,
 					VolumeMounts: []v1.VolumeMount{
 						{
 							Name:      "etcd-certs",
-							MountPath: "/etc/kubernetes/pki/",
+							MountPath: "/etc/kubernetes/pki/etcd",
 						},
 						{
 							Name:      "etcd-data",
@@ -342,7 +473,7 @@ func SetupEtcdStaticPodManifest(advertiseAddress string) error {
 					Name: "etcd-certs",
 					VolumeSource: v1.VolumeSource{
 						HostPath: &v1.HostPathVolumeSource{
-							Path: "/etc/kubernetes/pki/",
+							Path: "/etc/kubernetes/pki/etcd",
 							Type: &hostPathDirectoryOrCreate,
 						},
 					},
@@ -360,7 +491,7 @@ func SetupEtcdStaticPodManifest(advertiseAddress string) error {
 		},
 	}
 
-	err := writePodManifest(etcdPod, "/etc/kubernetes/manifests/etcd.yaml")
+	err = writePodManifest(etcdPod, "/etc/kubernetes/manifests/etcd.yaml")
 	if err != nil {
 		return fmt.Errorf("failed to write etcd manifest: %w", err)
 	}
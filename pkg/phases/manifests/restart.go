@@ -0,0 +1,107 @@
+package manifests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const restartedAtAnnotationKey = "k8sbootstrap.io/restarted-at"
+
+// certificateStaticPods lists the static pods that load each certificate
+// under /etc/kubernetes/pki, directly or through a kubeconfig.
+var certificateStaticPods = map[string][]string{
+	"apiserver":                {"kube-apiserver"},
+	"apiserver-kubelet-client": {"kube-apiserver"},
+	"apiserver-etcd-client":    {"kube-apiserver"},
+	"front-proxy-client":       {"kube-apiserver"},
+	"controller-manager":       {"kube-controller-manager"},
+	"scheduler":                {"kube-scheduler"},
+	"etcd/server":              {"etcd"},
+	"etcd/peer":                {"etcd"},
+}
+
+// StaticPodsForCertificate returns the static pods that must be restarted to
+// pick up a renewed certificate.
+func StaticPodsForCertificate(name string) []string {
+	return certificateStaticPods[name]
+}
+
+// RestartStaticPod makes the kubelet recreate the static pod by stamping its
+// manifest with a new annotation. The kubelet ignores a bare change of the
+// modification time, only a changed pod spec triggers a restart. The stamp is
+// returned for WaitForStaticPodRestart.
+func RestartStaticPod(name string) (string, error) {
+	path := fmt.Sprintf("/etc/kubernetes/manifests/%s.yaml", name)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	pod := &corev1.Pod{}
+	_, _, err = scheme.Codecs.UniversalDeserializer().Decode(data, nil, pod)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	restartedAt := time.Now().Format(time.RFC3339Nano)
+	pod.Annotations[restartedAtAnnotationKey] = restartedAt
+
+	err = writePodManifest(pod, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	fmt.Printf("[manifest] Restarting static pod %s\n", name)
+	return restartedAt, nil
+}
+
+// WaitForStaticPodRestart waits until the mirror pod of the static pod name
+// on nodeName carries the restartedAt stamp and is ready. The kubelet only
+// creates the new mirror pod once the old pod has terminated, so the old
+// container is gone by then.
+func WaitForStaticPodRestart(client kubernetes.Interface, name, nodeName, restartedAt string, timeout time.Duration) error {
+	mirrorPodName := fmt.Sprintf("%s-%s", name, nodeName)
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		pod, err := client.CoreV1().Pods(metav1.NamespaceSystem).Get(ctx, mirrorPodName, metav1.GetOptions{})
+		cancel()
+
+		switch {
+		case err != nil:
+			lastErr = err
+		case pod.Annotations[restartedAtAnnotationKey] != restartedAt:
+			lastErr = fmt.Errorf("pod %s has not been restarted yet", mirrorPodName)
+		case !isPodReady(pod):
+			lastErr = fmt.Errorf("pod %s is not ready", mirrorPodName)
+		default:
+			return nil
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+
+	return fmt.Errorf("static pod %s did not restart within %s: %v", name, timeout, lastErr)
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package manifests
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/network"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Probe timings follow the values kubeadm uses for its control plane pods.
+func livenessProbe(host, path string, port int, scheme corev1.URIScheme) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler:        httpGetHandler(host, path, port, scheme),
+		InitialDelaySeconds: 10,
+		TimeoutSeconds:      15,
+		PeriodSeconds:       10,
+		FailureThreshold:    8,
+	}
+}
+
+func readinessProbe(host, path string, port int, scheme corev1.URIScheme) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler:     httpGetHandler(host, path, port, scheme),
+		TimeoutSeconds:   15,
+		PeriodSeconds:    1,
+		FailureThreshold: 3,
+	}
+}
+
+func startupProbe(host, path string, port int, scheme corev1.URIScheme) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler:        httpGetHandler(host, path, port, scheme),
+		InitialDelaySeconds: 10,
+		TimeoutSeconds:      15,
+		PeriodSeconds:       10,
+		FailureThreshold:    24,
+	}
+}
+
+func httpGetHandler(host, path string, port int, scheme corev1.URIScheme) corev1.ProbeHandler {
+	return corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Host:   host,
+			Path:   path,
+			Port:   intstr.FromInt32(int32(port)),
+			Scheme: scheme,
+		},
+	}
+}
+
+func resourceRequirements(requests config.ResourceRequests) (corev1.ResourceRequirements, error) {
+	resourceList := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    requests.CPU,
+		corev1.ResourceMemory: requests.Memory,
+	} {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return corev1.ResourceRequirements{}, fmt.Errorf("%s %q: %w", name, value, err)
+		}
+		resourceList[name] = quantity
+	}
+	return corev1.ResourceRequirements{Requests: resourceList}, nil
+}
+
+const (
+	defaultNodeCIDRMaskSizeIPv4 = 24
+	defaultNodeCIDRMaskSizeIPv6 = 64
+)
+
+// nodeCIDRMaskSizeFlags returns the controller-manager node CIDR mask flags
+// for the pod subnets, using the per-family flags for dual-stack clusters.
+func nodeCIDRMaskSizeFlags(podSubnets string) ([]string, error) {
+	subnets, err := network.ParseCIDRs(podSubnets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod subnet %q: %w", podSubnets, err)
+	}
+
+	if len(subnets) == 1 {
+		return []string{fmt.Sprintf("--node-cidr-mask-size=%d", nodeCIDRMaskSize(subnets[0]))}, nil
+	}
+
+	var flags []string
+	for _, subnet := range subnets {
+		family := "ipv4"
+		if network.IsIPv6CIDR(subnet) {
+			family = "ipv6"
+		}
+		flags = append(flags, fmt.Sprintf("--node-cidr-mask-size-%s=%d", family, nodeCIDRMaskSize(subnet)))
+	}
+	return flags, nil
+}
+
+// nodeCIDRMaskSize uses the default per-node mask for the subnet's family,
+// but never a mask larger than the pod subnet itself.
+func nodeCIDRMaskSize(subnet *net.IPNet) int {
+	prefix, _ := subnet.Mask.Size()
+
+	maskSize := defaultNodeCIDRMaskSizeIPv4
+	if network.IsIPv6CIDR(subnet) {
+		maskSize = defaultNodeCIDRMaskSizeIPv6
+	}
+	if prefix > maskSize {
+		return prefix
+	}
+	return maskSize
+}
+
+// externalEtcdVolumes mounts the directories holding the external etcd
+// client certificates that are not already under /etc/kubernetes/pki.
+func externalEtcdVolumes(external *config.ExternalEtcd) ([]corev1.Volume, []corev1.VolumeMount) {
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	seen := map[string]bool{}
+
+	for _, file := range []string{external.CAFile, external.CertFile, external.KeyFile} {
+		if file == "" {
+			continue
+		}
+		dir := filepath.Dir(file)
+		if seen[dir] || strings.HasPrefix(dir+"/", "/etc/kubernetes/pki/") {
+			continue
+		}
+		seen[dir] = true
+
+		name := fmt.Sprintf("etcd-certs-%d", len(volumes))
+		volumes = append(volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: dir,
+					Type: &hostPathDirectoryOrCreate,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      name,
+			MountPath: dir,
+			ReadOnly:  true,
+		})
+	}
+	return volumes, volumeMounts
+}
@@ -1,12 +1,21 @@
 package preflight
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/kubelet"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/cgroups"
 	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/initsystem"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/network"
 )
 
 func CheckRoot() (errorList []error) {
@@ -68,6 +77,21 @@ func CheckContainerRuntime() (errorList []error) {
 	return nil
 }
 
+// CheckCgroups reports the cgroup version and requires a cgroup driver the
+// kubelet and containerd agree on.
+func CheckCgroups(cfg *config.ClusterConfiguration) (errorList []error) {
+	if cgroups.IsCgroup2UnifiedMode() {
+		fmt.Println("[preflight] Detected cgroup v2")
+	} else {
+		fmt.Println("[preflight] WARNING: Detected cgroup v1, which is deprecated. The kubelet will be configured to allow it")
+	}
+
+	if _, err := kubelet.ResolveCgroupDriver(cfg); err != nil {
+		errorList = append(errorList, err)
+	}
+	return errorList
+}
+
 func CheckIPForwarding() (errorList []error) {
 	output, err := os.ReadFile("/proc/sys/net/ipv4/ip_forward")
 	if err != nil {
@@ -80,6 +104,113 @@ func CheckIPForwarding() (errorList []error) {
 	return nil
 }
 
+func CheckIPv6Forwarding() (errorList []error) {
+	output, err := os.ReadFile("/proc/sys/net/ipv6/conf/default/forwarding")
+	if err != nil {
+		return []error{err}
+	}
+
+	if strings.TrimSpace(string(output)) != "1" {
+		return []error{fmt.Errorf("IPv6 forwarding is not enabled")}
+	}
+	return nil
+}
+
+// CheckSubnets verifies that the pod and service subnets do not overlap with
+// the networks of the node. Networks inside the cluster's own ranges, such as
+// the CNI bridge or kube-ipvs0 left by an earlier run, are ignored.
+func CheckSubnets(cfg *config.ClusterConfiguration) (errorList []error) {
+	nodeNetworks, err := network.NodeNetworks()
+	if err != nil {
+		return []error{err}
+	}
+
+	subnets := map[string][]*net.IPNet{}
+	for name, cidrs := range map[string]string{
+		"podSubnet":     cfg.Networking.PodSubnet,
+		"serviceSubnet": cfg.Networking.ServiceSubnet,
+	} {
+		parsed, err := network.ParseCIDRs(cidrs)
+		if err != nil {
+			errorList = append(errorList, fmt.Errorf("%s %q is invalid: %w", name, cidrs, err))
+			continue
+		}
+		subnets[name] = parsed
+	}
+
+	for _, nodeNetwork := range nodeNetworks {
+		if withinClusterSubnets(nodeNetwork, subnets) {
+			continue
+		}
+		for name, parsed := range subnets {
+			for _, subnet := range parsed {
+				if network.SubnetsOverlap(subnet, nodeNetwork) {
+					errorList = append(errorList,
+						fmt.Errorf("%s %s overlaps with node network %s", name, subnet, nodeNetwork))
+				}
+			}
+		}
+	}
+	return errorList
+}
+
+func withinClusterSubnets(nodeNetwork *net.IPNet, subnets map[string][]*net.IPNet) bool {
+	nodeOnes, _ := nodeNetwork.Mask.Size()
+	for _, parsed := range subnets {
+		for _, subnet := range parsed {
+			ones, _ := subnet.Mask.Size()
+			if subnet.Contains(nodeNetwork.IP) && nodeOnes >= ones {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckExternalEtcd verifies that every external etcd endpoint is reachable
+// and reports healthy using the configured client certificates.
+func CheckExternalEtcd(cfg *config.ClusterConfiguration) (errorList []error) {
+	external := cfg.Etcd.External
+
+	tlsConfig := &tls.Config{}
+	if external.CAFile != "" {
+		caCert, err := os.ReadFile(external.CAFile)
+		if err != nil {
+			return []error{fmt.Errorf("failed to read etcd CA file: %w", err)}
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return []error{fmt.Errorf("no certificates found in etcd CA file %s", external.CAFile)}
+		}
+	}
+	if external.CertFile != "" && external.KeyFile != "" {
+		clientCert, err := tls.LoadX509KeyPair(external.CertFile, external.KeyFile)
+		if err != nil {
+			return []error{fmt.Errorf("failed to load etcd client certificate: %w", err)}
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+
+	for _, endpoint := range external.Endpoints {
+		resp, err := client.Get(strings.TrimSuffix(endpoint, "/") + "/health")
+		if err != nil {
+			errorList = append(errorList, fmt.Errorf("etcd endpoint %s is not reachable: %w", endpoint, err))
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			errorList = append(errorList, fmt.Errorf("etcd endpoint %s is unhealthy: %s", endpoint, resp.Status))
+		}
+	}
+	return errorList
+}
+
 func CheckKubelet() (errorList []error) {
 	if err := ServiceCheck("kubelet"); err != nil {
 		return err
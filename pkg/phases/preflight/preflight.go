@@ -1,18 +1,37 @@
 package preflight
 
-import "fmt"
+import (
+	"fmt"
 
-func RunPreflightChecks() error {
-	checks := []struct {
-		name string
-		fn   func() []error
-	}{
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+)
+
+type check struct {
+	name string
+	fn   func() []error
+}
+
+func RunPreflightChecks(cfg *config.ClusterConfiguration) error {
+	checks := []check{
 		{"Checking if running as root", CheckRoot},
 		{"Checking if swap is enabled", CheckSwap},
 		{"Checking if ports are available", CheckPorts},
 		{"Checking for kernel modules", CheckKernelModules},
 		{"Checking container runtime", CheckContainerRuntime},
-		{"Checking IP forwarding", CheckIPForwarding},
+		{"Checking cgroups", func() []error { return CheckCgroups(cfg) }},
+		{"Checking pod and service subnets", func() []error { return CheckSubnets(cfg) }},
+	}
+
+	if cfg.Etcd.External != nil {
+		checks = append(checks, check{"Checking external etcd endpoints", func() []error { return CheckExternalEtcd(cfg) }})
+	}
+
+	if cfg.HasIPv4() {
+		checks = append(checks, check{"Checking IP forwarding", CheckIPForwarding})
+	}
+
+	if cfg.HasIPv6() {
+		checks = append(checks, check{"Checking IPv6 forwarding", CheckIPv6Forwarding})
 	}
 
 	fmt.Println("[preflight] Running preflight checks")
@@ -0,0 +1,213 @@
+package uploadcerts
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/config"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/bootstraptoken"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/phases/certificates"
+	"github.com/sreeram-venkitesh/k8sbootstrap/pkg/utils/apiclient"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	SecretName = "k8sbootstrap-certs"
+
+	certificateKeyLength = 32
+)
+
+// CreateCertificateKey returns a random AES-256 key, hex encoded as it is
+// passed to --certificate-key.
+func CreateCertificateKey() (string, error) {
+	key := make([]byte, certificateKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate certificate key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// UploadCerts encrypts the shared certificates with certificateKey and stores
+// them in the kube-system k8sbootstrap-certs Secret. The Secret is owned by
+// tokenSecret, so it is garbage collected when the bootstrap token expires.
+func UploadCerts(client kubernetes.Interface, cfg *config.ClusterConfiguration, certificateKey string, tokenSecret *corev1.Secret) error {
+	key, err := decodeCertificateKey(certificateKey)
+	if err != nil {
+		return err
+	}
+
+	data := map[string][]byte{}
+	for _, shared := range certificates.SharedCertificates(cfg) {
+		content, err := os.ReadFile(shared.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", shared.Path, err)
+		}
+
+		encrypted, err := encrypt(content, key)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", shared.Path, err)
+		}
+		data[shared.Name] = encrypted
+	}
+
+	err = apiclient.CreateOrUpdateSecret(client, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SecretName,
+			Namespace: metav1.NamespaceSystem,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "v1",
+					Kind:       "Secret",
+					Name:       tokenSecret.Name,
+					UID:        tokenSecret.UID,
+				},
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := createDownloadRBAC(client); err != nil {
+		return err
+	}
+
+	fmt.Printf("[upload-certs] Stored the shared certificates in the %s Secret in kube-system\n", SecretName)
+	return nil
+}
+
+// DownloadCerts fetches the k8sbootstrap-certs Secret, decrypts it with
+// certificateKey and writes the shared certificates to disk.
+func DownloadCerts(client kubernetes.Interface, cfg *config.ClusterConfiguration, certificateKey string) error {
+	key, err := decodeCertificateKey(certificateKey)
+	if err != nil {
+		return err
+	}
+
+	secret, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Get(context.TODO(), SecretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get the %s Secret, it may have expired: %w", SecretName, err)
+	}
+
+	for _, shared := range certificates.SharedCertificates(cfg) {
+		encrypted, ok := secret.Data[shared.Name]
+		if !ok && strings.HasSuffix(shared.Name, "ca.key") {
+			// The CA is external, only its certificate was uploaded.
+			continue
+		}
+		if !ok {
+			return fmt.Errorf("%s is missing from the %s Secret", shared.Name, SecretName)
+		}
+
+		content, err := decrypt(encrypted, key)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s, is the certificate key correct? %w", shared.Name, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(shared.Path), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(shared.Path), err)
+		}
+		if err := os.WriteFile(shared.Path, content, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", shared.Path, err)
+		}
+	}
+
+	fmt.Println("[download-certs] Downloaded the shared certificates")
+	return nil
+}
+
+// createDownloadRBAC lets bootstrap tokens read the certificates Secret, so
+// joining nodes can fetch it before they have any credentials of their own.
+func createDownloadRBAC(client kubernetes.Interface) error {
+	err := apiclient.CreateOrUpdateRole(client, &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sbootstrap:" + SecretName,
+			Namespace: metav1.NamespaceSystem,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				Verbs:         []string{"get"},
+				APIGroups:     []string{""},
+				Resources:     []string{"secrets"},
+				ResourceNames: []string{SecretName},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return apiclient.CreateOrUpdateRoleBinding(client, &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sbootstrap:" + SecretName,
+			Namespace: metav1.NamespaceSystem,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     "k8sbootstrap:" + SecretName,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind: rbacv1.GroupKind,
+				Name: bootstraptoken.NodeBootstrapTokenGroup,
+			},
+		},
+	})
+}
+
+func decodeCertificateKey(certificateKey string) ([]byte, error) {
+	key, err := hex.DecodeString(certificateKey)
+	if err != nil || len(key) != certificateKeyLength {
+		return nil, fmt.Errorf("certificate key must be %d hex-encoded bytes", certificateKeyLength)
+	}
+	return key, nil
+}
+
+// encrypt seals data with AES-GCM, prefixing the random nonce.
+func encrypt(data []byte, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+func decrypt(data []byte, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
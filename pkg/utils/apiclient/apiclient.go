@@ -0,0 +1,102 @@
+package apiclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// WaitForAPIServer polls /healthz until the API server reports healthy or
+// the timeout expires.
+func WaitForAPIServer(client kubernetes.Interface, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		result := client.Discovery().RESTClient().Get().AbsPath("/healthz").Do(ctx)
+		cancel()
+
+		var statusCode int
+		result.StatusCode(&statusCode)
+		if result.Error() == nil && statusCode == 200 {
+			return nil
+		}
+
+		lastErr = result.Error()
+		time.Sleep(2 * time.Second)
+	}
+
+	return fmt.Errorf("API server did not become healthy within %s: %v", timeout, lastErr)
+}
+
+func CreateOrUpdateSecret(client kubernetes.Interface, secret *corev1.Secret) error {
+	secrets := client.CoreV1().Secrets(secret.Namespace)
+	if _, err := secrets.Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create secret %s: %w", secret.Name, err)
+		}
+		if _, err := secrets.Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update secret %s: %w", secret.Name, err)
+		}
+	}
+	return nil
+}
+
+func CreateOrUpdateConfigMap(client kubernetes.Interface, configMap *corev1.ConfigMap) error {
+	configMaps := client.CoreV1().ConfigMaps(configMap.Namespace)
+	if _, err := configMaps.Create(context.TODO(), configMap, metav1.CreateOptions{}); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create configmap %s: %w", configMap.Name, err)
+		}
+		if _, err := configMaps.Update(context.TODO(), configMap, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update configmap %s: %w", configMap.Name, err)
+		}
+	}
+	return nil
+}
+
+func CreateOrUpdateRole(client kubernetes.Interface, role *rbacv1.Role) error {
+	roles := client.RbacV1().Roles(role.Namespace)
+	if _, err := roles.Create(context.TODO(), role, metav1.CreateOptions{}); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create role %s: %w", role.Name, err)
+		}
+		if _, err := roles.Update(context.TODO(), role, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update role %s: %w", role.Name, err)
+		}
+	}
+	return nil
+}
+
+func CreateOrUpdateRoleBinding(client kubernetes.Interface, roleBinding *rbacv1.RoleBinding) error {
+	roleBindings := client.RbacV1().RoleBindings(roleBinding.Namespace)
+	if _, err := roleBindings.Create(context.TODO(), roleBinding, metav1.CreateOptions{}); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create rolebinding %s: %w", roleBinding.Name, err)
+		}
+		if _, err := roleBindings.Update(context.TODO(), roleBinding, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update rolebinding %s: %w", roleBinding.Name, err)
+		}
+	}
+	return nil
+}
+
+func CreateOrUpdateClusterRoleBinding(client kubernetes.Interface, clusterRoleBinding *rbacv1.ClusterRoleBinding) error {
+	clusterRoleBindings := client.RbacV1().ClusterRoleBindings()
+	if _, err := clusterRoleBindings.Create(context.TODO(), clusterRoleBinding, metav1.CreateOptions{}); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create clusterrolebinding %s: %w", clusterRoleBinding.Name, err)
+		}
+		if _, err := clusterRoleBindings.Update(context.TODO(), clusterRoleBinding, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update clusterrolebinding %s: %w", clusterRoleBinding.Name, err)
+		}
+	}
+	return nil
+}
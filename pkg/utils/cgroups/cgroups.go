@@ -0,0 +1,19 @@
+package cgroups
+
+import (
+	"os"
+)
+
+// IsCgroup2UnifiedMode reports whether /sys/fs/cgroup is mounted as the
+// cgroup v2 unified hierarchy.
+func IsCgroup2UnifiedMode() bool {
+	_, err := os.Stat("/sys/fs/cgroup/cgroup.controllers")
+	return err == nil
+}
+
+// IsSystemdRunning reports whether systemd is the init system, which the
+// systemd cgroup driver relies on.
+func IsSystemdRunning() bool {
+	_, err := os.Stat("/run/systemd/system")
+	return err == nil
+}
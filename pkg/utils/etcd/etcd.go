@@ -0,0 +1,149 @@
+package etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// AdvertiseClientURLsAnnotationKey is set on etcd static pods so joining
+// control plane nodes can discover the existing members through the API.
+const AdvertiseClientURLsAnnotationKey = "k8sbootstrap.io/etcd.advertise-client-urls"
+
+const (
+	dialTimeout    = 10 * time.Second
+	requestTimeout = 10 * time.Second
+)
+
+// Member is an etcd cluster member as needed for --initial-cluster.
+type Member struct {
+	Name    string
+	PeerURL string
+}
+
+type Client struct {
+	Endpoints []string
+	tlsConfig *tls.Config
+}
+
+func NewClient(endpoints []string, caFile string, certFile string, keyFile string) (*Client, error) {
+	tlsInfo := transport.TLSInfo{
+		CertFile:      certFile,
+		KeyFile:       keyFile,
+		TrustedCAFile: caFile,
+	}
+	tlsConfig, err := tlsInfo.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build etcd client TLS config: %w", err)
+	}
+
+	return &Client{
+		Endpoints: endpoints,
+		tlsConfig: tlsConfig,
+	}, nil
+}
+
+func (c *Client) withClient(fn func(cli *clientv3.Client) error) error {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   c.Endpoints,
+		DialTimeout: dialTimeout,
+		TLS:         c.tlsConfig,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to etcd at %v: %w", c.Endpoints, err)
+	}
+	defer cli.Close()
+
+	return fn(cli)
+}
+
+// AddLearner adds a non-voting member with the given peer URL and returns
+// its ID together with the full member list to start it with. Adding a
+// member whose peer URL is already registered is a no-op, so an interrupted
+// join can be retried.
+func (c *Client) AddLearner(name string, peerURL string) (uint64, []Member, error) {
+	var memberID uint64
+	var members []Member
+
+	err := c.withClient(func(cli *clientv3.Client) error {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
+		list, err := cli.MemberList(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list etcd members: %w", err)
+		}
+
+		existing := list.Members
+		for _, m := range existing {
+			for _, url := range m.PeerURLs {
+				if url == peerURL {
+					memberID = m.ID
+				}
+			}
+		}
+
+		if memberID == 0 {
+			resp, err := cli.MemberAddAsLearner(ctx, []string{peerURL})
+			if err != nil {
+				return fmt.Errorf("failed to add etcd learner member: %w", err)
+			}
+			memberID = resp.Member.ID
+			existing = resp.Members
+		}
+
+		for _, m := range existing {
+			memberName := m.Name
+			if m.ID == memberID {
+				// Members that have not started yet have no name.
+				memberName = name
+			}
+			if len(m.PeerURLs) == 0 {
+				continue
+			}
+			members = append(members, Member{Name: memberName, PeerURL: m.PeerURLs[0]})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return memberID, members, nil
+}
+
+// PromoteLearner turns a learner into a voting member. etcd refuses to do so
+// until the learner has caught up with the leader, so this retries until the
+// timeout expires.
+func (c *Client) PromoteLearner(memberID uint64, timeout time.Duration) error {
+	return c.withClient(func(cli *clientv3.Client) error {
+		deadline := time.Now().Add(timeout)
+		var lastErr error
+
+		for time.Now().Before(deadline) {
+			ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+			list, err := cli.MemberList(ctx)
+			if err == nil {
+				for _, m := range list.Members {
+					if m.ID == memberID && !m.IsLearner {
+						cancel()
+						return nil
+					}
+				}
+				_, err = cli.MemberPromote(ctx, memberID)
+			}
+			cancel()
+			if err == nil {
+				return nil
+			}
+
+			lastErr = err
+			time.Sleep(5 * time.Second)
+		}
+
+		return fmt.Errorf("timed out promoting etcd member %x: %w", memberID, lastErr)
+	})
+}
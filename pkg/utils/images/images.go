@@ -0,0 +1,27 @@
+package images
+
+import "fmt"
+
+// Versions of the images run on every node. The pause version is the one
+// shipped with the Kubernetes release, as listed by kubeadm config images.
+const (
+	Repository        = "registry.k8s.io"
+	KubernetesVersion = "v1.35.0"
+	EtcdVersion       = "3.6.6-0"
+	PauseVersion      = "3.10.1"
+)
+
+// KubernetesImage returns the image of a control plane component, such as
+// kube-apiserver.
+func KubernetesImage(component string) string {
+	return fmt.Sprintf("%s/%s:%s", Repository, component, KubernetesVersion)
+}
+
+func EtcdImage() string {
+	return fmt.Sprintf("%s/etcd:%s", Repository, EtcdVersion)
+}
+
+// PauseImage returns the sandbox image containerd uses for pods.
+func PauseImage() string {
+	return fmt.Sprintf("%s/pause:%s", Repository, PauseVersion)
+}
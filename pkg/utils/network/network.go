@@ -0,0 +1,110 @@
+package network
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// GetIndexedIP returns the IP at the given offset from the start of subnet,
+// e.g. index 1 of 10.96.0.0/12 is 10.96.0.1.
+func GetIndexedIP(subnet *net.IPNet, index int) (net.IP, error) {
+	base := big.NewInt(0).SetBytes(subnet.IP)
+	ip := addIPOffset(base, index, len(subnet.IP))
+
+	if !subnet.Contains(ip) {
+		return nil, fmt.Errorf("can't generate IP with index %d from subnet %s: subnet too small", index, subnet)
+	}
+	return ip, nil
+}
+
+// APIServerVirtualIP returns the ClusterIP of the kubernetes service, which
+// is always the first address of the primary service subnet.
+func APIServerVirtualIP(serviceSubnets string) (net.IP, error) {
+	subnets, err := ParseCIDRs(serviceSubnets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service subnet %q: %w", serviceSubnets, err)
+	}
+	return GetIndexedIP(subnets[0], 1)
+}
+
+// ClusterDNSIP returns the ClusterIP reserved for the cluster DNS service,
+// the tenth address of the primary service subnet.
+func ClusterDNSIP(serviceSubnets string) (net.IP, error) {
+	subnets, err := ParseCIDRs(serviceSubnets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service subnet %q: %w", serviceSubnets, err)
+	}
+	return GetIndexedIP(subnets[0], 10)
+}
+
+// ParseCIDRs parses a comma-separated list of CIDRs, as used for dual-stack
+// pod and service subnets.
+func ParseCIDRs(cidrs string) ([]*net.IPNet, error) {
+	var subnets []*net.IPNet
+	for _, cidr := range strings.Split(cidrs, ",") {
+		_, subnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
+
+func IsIPv6(ip net.IP) bool {
+	return ip != nil && ip.To4() == nil
+}
+
+func IsIPv6CIDR(subnet *net.IPNet) bool {
+	return IsIPv6(subnet.IP)
+}
+
+// LoopbackAddress returns the loopback address of the same family as ip, so
+// that local endpoints stay reachable on IPv6-only nodes.
+func LoopbackAddress(ip string) string {
+	if IsIPv6(net.ParseIP(ip)) {
+		return "::1"
+	}
+	return "127.0.0.1"
+}
+
+// FormatURL builds a URL for host and port, bracketing IPv6 literals.
+func FormatURL(scheme string, host string, port int) string {
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// SubnetsOverlap reports whether two subnets share any address.
+func SubnetsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// NodeNetworks returns the networks of all addresses assigned to the host's
+// non-loopback interfaces.
+func NodeNetworks() ([]*net.IPNet, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interface addresses: %w", err)
+	}
+
+	var networks []*net.IPNet
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() {
+			continue
+		}
+		networks = append(networks, &net.IPNet{
+			IP:   ipNet.IP.Mask(ipNet.Mask),
+			Mask: ipNet.Mask,
+		})
+	}
+	return networks, nil
+}
+
+func addIPOffset(base *big.Int, offset int, length int) net.IP {
+	r := big.NewInt(0).Add(base, big.NewInt(int64(offset))).Bytes()
+	r = append(make([]byte, length), r...)
+	return net.IP(r[len(r)-length:])
+}
@@ -0,0 +1,168 @@
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	ipv4RouteFile = "/proc/net/route"
+	ipv6RouteFile = "/proc/net/ipv6_route"
+
+	rtfUp = 0x1
+)
+
+type defaultRoute struct {
+	iface  string
+	metric uint64
+}
+
+// ChooseHostAddress returns an address of the interface holding the default
+// route, preferring IPv4 and falling back to IPv6. The interface name is
+// returned alongside it so callers can explain the choice.
+func ChooseHostAddress() (net.IP, string, error) {
+	families := []struct {
+		ipv6  bool
+		parse func() (*defaultRoute, error)
+	}{
+		{false, getIPv4DefaultRoute},
+		{true, getIPv6DefaultRoute},
+	}
+
+	for _, family := range families {
+		route, err := family.parse()
+		if err != nil {
+			return nil, "", err
+		}
+		if route == nil {
+			continue
+		}
+
+		ip, err := getInterfaceAddress(route.iface, family.ipv6)
+		if err != nil {
+			return nil, "", err
+		}
+		if ip != nil {
+			return ip, route.iface, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("no default route with a usable global unicast address was found")
+}
+
+func getIPv4DefaultRoute() (*defaultRoute, error) {
+	// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+	return findDefaultRoute(ipv4RouteFile, func(fields []string) (*defaultRoute, bool) {
+		if len(fields) < 8 || fields[0] == "Iface" {
+			return nil, false
+		}
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			return nil, false
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 {
+			return nil, false
+		}
+		metric, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		return &defaultRoute{iface: fields[0], metric: metric}, true
+	})
+}
+
+func getIPv6DefaultRoute() (*defaultRoute, error) {
+	// Destination DestPrefix Source SourcePrefix NextHop Metric RefCnt Use Flags Iface
+	return findDefaultRoute(ipv6RouteFile, func(fields []string) (*defaultRoute, bool) {
+		if len(fields) < 10 {
+			return nil, false
+		}
+		if fields[0] != strings.Repeat("0", 32) || fields[1] != "00" || fields[9] == "lo" {
+			return nil, false
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil || flags&rtfUp == 0 {
+			return nil, false
+		}
+		metric, err := strconv.ParseUint(fields[5], 16, 64)
+		if err != nil {
+			return nil, false
+		}
+		return &defaultRoute{iface: fields[9], metric: metric}, true
+	})
+}
+
+// findDefaultRoute returns the default route with the lowest metric in a
+// procfs route table, or nil if there is none.
+func findDefaultRoute(path string, parse func(fields []string) (*defaultRoute, bool)) (*defaultRoute, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var best *defaultRoute
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		route, ok := parse(strings.Fields(scanner.Text()))
+		if !ok {
+			continue
+		}
+		if best == nil || route.metric < best.metric {
+			best = route
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return best, nil
+}
+
+func getInterfaceAddress(name string, ipv6 bool) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface %s: %w", name, err)
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return nil, nil
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses of interface %s: %w", name, err)
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || IsIPv6(ipNet.IP) != ipv6 {
+			continue
+		}
+		if ValidateHostAddress(ipNet.IP) == nil {
+			return ipNet.IP, nil
+		}
+	}
+	return nil, nil
+}
+
+// ValidateHostAddress rejects addresses other nodes can't use to reach the
+// API server, such as loopback and link-local addresses.
+func ValidateHostAddress(ip net.IP) error {
+	switch {
+	case ip == nil:
+		return fmt.Errorf("address is not a valid IP")
+	case ip.IsLoopback():
+		return fmt.Errorf("%s is a loopback address", ip)
+	case ip.IsLinkLocalUnicast():
+		return fmt.Errorf("%s is a link-local address", ip)
+	case !ip.IsGlobalUnicast():
+		return fmt.Errorf("%s is not a global unicast address", ip)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const (
+	ContainerdEndpoint   = "unix:///var/run/containerd/containerd.sock"
+	ContainerdConfigPath = "/etc/containerd/config.toml"
+
+	CgroupDriverSystemd  = "systemd"
+	CgroupDriverCgroupfs = "cgroupfs"
+)
+
+// runcOptionsRegexp matches the options table of the runc runtime, in the
+// version 2 and version 3 configuration formats.
+var runcOptionsRegexp = regexp.MustCompile(`(?m)^\s*\[plugins\.["'](io\.containerd\.grpc\.v1\.cri|io\.containerd\.cri\.v1\.runtime)["']\.containerd\.runtimes\.runc\.options\]\s*$`)
+
+var tableRegexp = regexp.MustCompile(`(?m)^\s*\[`)
+
+var systemdCgroupRegexp = regexp.MustCompile(`(?m)^\s*SystemdCgroup\s*=\s*(true|false)\s*$`)
+
+// CgroupDriver returns the cgroup driver used by containerd. It is read from
+// the CRI RuntimeConfig call, and from the SystemdCgroup setting in
+// /etc/containerd/config.toml for runtimes that do not implement it yet.
+func CgroupDriver() (string, error) {
+	driver, err := criCgroupDriver()
+	if err == nil {
+		return driver, nil
+	}
+	if status.Code(err) != codes.Unimplemented {
+		return "", fmt.Errorf("failed to get cgroup driver from containerd at %s, is it running? %w", ContainerdEndpoint, err)
+	}
+
+	driver, fileErr := containerdConfigCgroupDriver()
+	if fileErr != nil {
+		return "", fmt.Errorf("failed to get cgroup driver from the CRI (%s) and from %s: %w", err, ContainerdConfigPath, fileErr)
+	}
+	return driver, nil
+}
+
+func criCgroupDriver() (string, error) {
+	conn, err := grpc.NewClient(ContainerdEndpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := runtimeapi.NewRuntimeServiceClient(conn).RuntimeConfig(ctx, &runtimeapi.RuntimeConfigRequest{})
+	if err != nil {
+		return "", err
+	}
+	if resp.GetLinux() == nil {
+		return "", fmt.Errorf("runtime returned no Linux configuration")
+	}
+
+	switch resp.GetLinux().GetCgroupDriver() {
+	case runtimeapi.CgroupDriver_SYSTEMD:
+		return CgroupDriverSystemd, nil
+	case runtimeapi.CgroupDriver_CGROUPFS:
+		return CgroupDriverCgroupfs, nil
+	}
+	return "", fmt.Errorf("unknown cgroup driver %v", resp.GetLinux().GetCgroupDriver())
+}
+
+// containerdConfigCgroupDriver reads the SystemdCgroup option of the runc
+// runtime. containerd uses cgroupfs when it is not set.
+func containerdConfigCgroupDriver() (string, error) {
+	data, err := os.ReadFile(ContainerdConfigPath)
+	if os.IsNotExist(err) {
+		return CgroupDriverCgroupfs, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	header := runcOptionsRegexp.FindIndex(data)
+	if header == nil {
+		return CgroupDriverCgroupfs, nil
+	}
+	options := data[header[1]:]
+	if next := tableRegexp.FindIndex(options); next != nil {
+		options = options[:next[0]]
+	}
+
+	match := systemdCgroupRegexp.FindSubmatch(options)
+	if match != nil && string(match[1]) == "true" {
+		return CgroupDriverSystemd, nil
+	}
+	return CgroupDriverCgroupfs, nil
+}